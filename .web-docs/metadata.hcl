@@ -12,4 +12,49 @@ integration {
     name = "Hetzner Cloud"
     slug = "hcloud"
   }
+  component {
+    type = "data-source"
+    name = "Hetzner Cloud Datacenter"
+    slug = "datacenter"
+  }
+  component {
+    type = "data-source"
+    name = "Hetzner Cloud Image"
+    slug = "image"
+  }
+  component {
+    type = "data-source"
+    name = "Hetzner Cloud ISO"
+    slug = "iso"
+  }
+  component {
+    type = "provisioner"
+    name = "Hetzner Cloud Power"
+    slug = "power"
+  }
+  component {
+    type = "provisioner"
+    name = "Hetzner Cloud Snapshot"
+    slug = "snapshot"
+  }
+  component {
+    type = "post-processor"
+    name = "Hetzner Cloud Export"
+    slug = "export"
+  }
+  component {
+    type = "post-processor"
+    name = "Hetzner Cloud Import"
+    slug = "import"
+  }
+  component {
+    type = "post-processor"
+    name = "Hetzner Cloud Snapshot Lifecycle"
+    slug = "snapshot-lifecycle"
+  }
+  component {
+    type = "post-processor"
+    name = "Hetzner Cloud Snapshot Retention"
+    slug = "snapshot-retention"
+  }
 }
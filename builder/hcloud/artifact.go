@@ -5,10 +5,15 @@ package hcloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
@@ -24,6 +29,10 @@ type Artifact struct {
 	// The hcloudClient for making API calls
 	hcloudClient *hcloud.Client
 
+	// The snapshots replicated to other projects, with the client of their
+	// project
+	replicas []*Artifact
+
 	// StateData should store data such as GeneratedData
 	// to be shared with post-processors
 	StateData map[string]interface{}
@@ -38,11 +47,43 @@ func (*Artifact) Files() []string {
 }
 
 func (a *Artifact) Id() string {
+	if snapshotIDs := a.snapshotIDs(); len(snapshotIDs) > 1 {
+		parts := make([]string, 0, len(snapshotIDs))
+		for _, architecture := range slices.Sorted(maps.Keys(snapshotIDs)) {
+			parts = append(parts, fmt.Sprintf("%s:%d", architecture, snapshotIDs[architecture]))
+		}
+		return strings.Join(parts, ",")
+	}
 	return strconv.FormatInt(a.snapshotId, 10)
 }
 
 func (a *Artifact) String() string {
-	return fmt.Sprintf("A snapshot was created: '%v' (ID: %v)", a.snapshotName, a.snapshotId)
+	var s string
+	if snapshotIDs := a.snapshotIDs(); len(snapshotIDs) > 1 {
+		parts := make([]string, 0, len(snapshotIDs))
+		for _, architecture := range slices.Sorted(maps.Keys(snapshotIDs)) {
+			parts = append(parts, fmt.Sprintf("%s: %d", architecture, snapshotIDs[architecture]))
+		}
+		s = fmt.Sprintf("Snapshots were created: '%v' (IDs: %v)", a.snapshotName, strings.Join(parts, ", "))
+	} else {
+		s = fmt.Sprintf("A snapshot was created: '%v' (ID: %v)", a.snapshotName, a.snapshotId)
+	}
+
+	if intermediateSnapshotIDs, _ := a.StateData["intermediate_snapshot_ids"].([]int64); len(intermediateSnapshotIDs) > 0 {
+		parts := make([]string, 0, len(intermediateSnapshotIDs))
+		for _, snapshotID := range intermediateSnapshotIDs {
+			parts = append(parts, strconv.FormatInt(snapshotID, 10))
+		}
+		s += fmt.Sprintf(", intermediate snapshots were created (IDs: %v)", strings.Join(parts, ", "))
+	}
+	return s
+}
+
+// snapshotIDs returns the IDs of the snapshots per architecture, when the image
+// was built for multiple architectures.
+func (a *Artifact) snapshotIDs() map[string]int64 {
+	snapshotIDs, _ := a.StateData["snapshot_ids"].(map[string]int64)
+	return snapshotIDs
 }
 
 func (a *Artifact) State(name string) interface{} {
@@ -53,25 +94,49 @@ func (a *Artifact) State(name string) interface{} {
 }
 
 func (a *Artifact) stateHCPPackerRegistryMetadata() interface{} {
+	architectures, ok := a.StateData["architectures"].(map[string]map[string]interface{})
+	if ok && len(architectures) > 1 {
+		// Report one image per architecture
+		images := make([]*registryimage.Image, 0, len(architectures))
+		for _, architecture := range slices.Sorted(maps.Keys(architectures)) {
+			stateData := architectures[architecture]
+			snapshotID, _ := stateData["snapshot_id"].(int64)
+
+			img := stateHCPPackerRegistryImage(snapshotID, stateData)
+			img.Labels["architecture"] = architecture
+			images = append(images, img)
+		}
+		return images
+	}
+
+	return stateHCPPackerRegistryImage(a.snapshotId, a.StateData)
+}
+
+func stateHCPPackerRegistryImage(snapshotID int64, stateData map[string]interface{}) *registryimage.Image {
 	labels := make(map[string]string)
 
 	// Those labels contains the value the user specified in their template
-	sourceImage, ok := a.StateData["source_image"].(string)
+	sourceImage, ok := stateData["source_image"].(string)
 	if ok {
 		labels["source_image"] = sourceImage
 	}
-	serverType, ok := a.StateData["server_type"].(string)
+	serverType, ok := stateData["server_type"].(string)
 	if ok {
 		labels["server_type"] = serverType
 	}
 
 	img := &registryimage.Image{
-		ImageID:      a.Id(),
+		ImageID:      strconv.FormatInt(snapshotID, 10),
 		ProviderName: "hetznercloud", // Use explicit name over the builder ID
 		Labels:       labels,
 	}
 
-	sourceImageID, ok := a.StateData["source_image_id"].(int64)
+	location, ok := stateData["location"].(string)
+	if ok {
+		img.ProviderRegion = location
+	}
+
+	sourceImageID, ok := stateData["source_image_id"].(int64)
 	if ok {
 		img.SourceImageID = strconv.FormatInt(sourceImageID, 10)
 	}
@@ -80,7 +145,140 @@ func (a *Artifact) stateHCPPackerRegistryMetadata() interface{} {
 }
 
 func (a *Artifact) Destroy() error {
-	log.Printf("Destroying image: %d (%s)", a.snapshotId, a.snapshotName)
-	_, err := a.hcloudClient.Image.Delete(context.TODO(), &hcloud.Image{ID: a.snapshotId})
+	protected, _ := a.StateData["protected"].(bool)
+
+	var errs []error
+	if snapshotIDs := a.snapshotIDs(); len(snapshotIDs) > 1 {
+		for _, architecture := range slices.Sorted(maps.Keys(snapshotIDs)) {
+			log.Printf("Destroying image: %d (%s, %s)", snapshotIDs[architecture], a.snapshotName, architecture)
+			if err := a.destroyImage(snapshotIDs[architecture], protected); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	} else {
+		log.Printf("Destroying image: %d (%s)", a.snapshotId, a.snapshotName)
+		if err := a.destroyImage(a.snapshotId, protected); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Intermediate snapshots are created by the hcloud-snapshot provisioner,
+	// they are never protected
+	intermediateSnapshotIDs, _ := a.StateData["intermediate_snapshot_ids"].([]int64)
+	for _, snapshotID := range intermediateSnapshotIDs {
+		log.Printf("Destroying intermediate image: %d", snapshotID)
+		if err := a.destroyImage(snapshotID, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, replica := range a.replicas {
+		log.Printf("Destroying replicated image: %d", replica.snapshotId)
+		if err := replica.Destroy(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (a *Artifact) destroyImage(imageID int64, protected bool) error {
+	ctx := context.TODO()
+	image := &hcloud.Image{ID: imageID}
+
+	// Protected images must be unprotected before they can be deleted
+	if protected {
+		action, _, err := a.hcloudClient.Image.ChangeProtection(ctx, image, hcloud.ImageChangeProtectionOpts{
+			Delete: hcloud.Ptr(false),
+		})
+		if err != nil {
+			return err
+		}
+		if err := a.hcloudClient.Action.WaitFor(ctx, action); err != nil {
+			return err
+		}
+	}
+
+	_, err := a.hcloudClient.Image.Delete(ctx, image)
 	return err
 }
+
+// SnapshotIDsFromArtifact returns the IDs of the snapshots referenced by an
+// artifact of this builder. Artifacts received by post-processors are RPC
+// clients, the IDs are therefore parsed from the artifact ID.
+func SnapshotIDsFromArtifact(artifact packersdk.Artifact) ([]int64, error) {
+	if artifact.BuilderId() != BuilderId {
+		return nil, fmt.Errorf("unsupported artifact type %q, only %q is supported", artifact.BuilderId(), BuilderId)
+	}
+
+	var snapshotIDs []int64
+	for _, part := range strings.Split(artifact.Id(), ",") {
+		// Artifacts built for multiple architectures use the "<arch>:<id>" format
+		if _, value, found := strings.Cut(part, ":"); found {
+			part = value
+		}
+		snapshotID, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid artifact ID %q: %w", artifact.Id(), err)
+		}
+		snapshotIDs = append(snapshotIDs, snapshotID)
+	}
+	return snapshotIDs, nil
+}
+
+// mergeArtifacts combines the artifacts built for each architecture into a
+// single artifact. The first artifact is used as the primary artifact, the
+// snapshot IDs of all architectures are stored in the state data.
+func mergeArtifacts(artifacts []*Artifact) *Artifact {
+	if len(artifacts) == 1 {
+		return artifacts[0]
+	}
+
+	snapshotIDs := make(map[string]int64, len(artifacts))
+	architectures := make(map[string]map[string]interface{}, len(artifacts))
+	var intermediateSnapshotIDs []int64
+	var replicas []*Artifact
+	replicatedSnapshotIDs := make(map[string]map[string]int64)
+	for _, artifact := range artifacts {
+		architecture := artifact.StateData["architecture"].(string)
+
+		stateData := maps.Clone(artifact.StateData)
+		stateData["snapshot_id"] = artifact.snapshotId
+
+		snapshotIDs[architecture] = artifact.snapshotId
+		architectures[architecture] = stateData
+
+		ids, _ := artifact.StateData["intermediate_snapshot_ids"].([]int64)
+		intermediateSnapshotIDs = append(intermediateSnapshotIDs, ids...)
+
+		replicas = append(replicas, artifact.replicas...)
+		projectSnapshotIDs, _ := artifact.StateData["replicated_snapshot_ids"].(map[string]int64)
+		for project, snapshotID := range projectSnapshotIDs {
+			if replicatedSnapshotIDs[project] == nil {
+				replicatedSnapshotIDs[project] = make(map[string]int64, len(artifacts))
+			}
+			replicatedSnapshotIDs[project][architecture] = snapshotID
+		}
+	}
+
+	primary := artifacts[0]
+	stateData := maps.Clone(primary.StateData)
+	stateData["snapshot_ids"] = snapshotIDs
+	stateData["architectures"] = architectures
+	delete(stateData, "intermediate_snapshot_ids")
+	if len(intermediateSnapshotIDs) > 0 {
+		stateData["intermediate_snapshot_ids"] = intermediateSnapshotIDs
+	}
+	// The replicated snapshots are stored per project and per architecture
+	delete(stateData, "replicated_snapshot_ids")
+	if len(replicatedSnapshotIDs) > 0 {
+		stateData["replicated_snapshot_ids"] = replicatedSnapshotIDs
+	}
+
+	return &Artifact{
+		snapshotName: primary.snapshotName,
+		snapshotId:   primary.snapshotId,
+		hcloudClient: primary.hcloudClient,
+		StateData:    stateData,
+		replicas:     replicas,
+	}
+}
@@ -4,6 +4,7 @@
 package hcloud
 
 import (
+	"net/http/httptest"
 	"testing"
 
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
@@ -11,6 +12,9 @@ import (
 	"github.com/mitchellh/mapstructure"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
 )
 
 func TestArtifact_Impl(t *testing.T) {
@@ -19,7 +23,7 @@ func TestArtifact_Impl(t *testing.T) {
 
 func TestArtifactId(t *testing.T) {
 	generatedData := make(map[string]interface{})
-	a := &Artifact{"packer-foobar", 42, nil, generatedData}
+	a := &Artifact{snapshotName: "packer-foobar", snapshotId: 42, StateData: generatedData}
 	expected := "42"
 
 	if a.Id() != expected {
@@ -29,7 +33,7 @@ func TestArtifactId(t *testing.T) {
 
 func TestArtifactString(t *testing.T) {
 	generatedData := make(map[string]interface{})
-	a := &Artifact{"packer-foobar", 42, nil, generatedData}
+	a := &Artifact{snapshotName: "packer-foobar", snapshotId: 42, StateData: generatedData}
 	expected := "A snapshot was created: 'packer-foobar' (ID: 42)"
 
 	if a.String() != expected {
@@ -37,6 +41,17 @@ func TestArtifactString(t *testing.T) {
 	}
 }
 
+func TestArtifactString_IntermediateSnapshots(t *testing.T) {
+	a := &Artifact{snapshotName: "packer-foobar", snapshotId: 42, StateData: map[string]interface{}{
+		"intermediate_snapshot_ids": []int64{40, 41},
+	}}
+	expected := "A snapshot was created: 'packer-foobar' (ID: 42), intermediate snapshots were created (IDs: 40, 41)"
+
+	if a.String() != expected {
+		t.Fatalf("artifact string should match: %v", expected)
+	}
+}
+
 func TestArtifactState_StateData(t *testing.T) {
 	expectedData := "this is the data"
 	artifact := &Artifact{
@@ -71,6 +86,7 @@ func TestArtifactState_hcpPackerRegistryMetadata(t *testing.T) {
 			"source_image":    "ubuntu-24.04",
 			"source_image_id": int64(161547269),
 			"server_type":     "cpx22",
+			"location":        "fsn1",
 		},
 	}
 
@@ -83,12 +99,219 @@ func TestArtifactState_hcpPackerRegistryMetadata(t *testing.T) {
 	}
 
 	assert.Equal(t, registryimage.Image{
-		ImageID:       "167438588",
-		ProviderName:  "hetznercloud",
-		SourceImageID: "161547269",
+		ImageID:        "167438588",
+		ProviderName:   "hetznercloud",
+		ProviderRegion: "fsn1",
+		SourceImageID:  "161547269",
 		Labels: map[string]string{
 			"source_image": "ubuntu-24.04",
 			"server_type":  "cpx22",
 		},
 	}, image)
 }
+
+func TestArtifact_multipleArchitectures(t *testing.T) {
+	artifact := mergeArtifacts([]*Artifact{
+		{
+			snapshotId:   167438588,
+			snapshotName: "test-image",
+			StateData: map[string]interface{}{
+				"source_image":    "ubuntu-24.04",
+				"source_image_id": int64(161547270),
+				"server_type":     "cax11",
+				"location":        "fsn1",
+				"architecture":    "arm",
+
+				"replicated_snapshot_ids": map[string]int64{"staging": 167438590},
+			},
+			replicas: []*Artifact{{snapshotId: 167438590}},
+		},
+		{
+			snapshotId:   167438589,
+			snapshotName: "test-image",
+			StateData: map[string]interface{}{
+				"source_image":    "ubuntu-24.04",
+				"source_image_id": int64(161547269),
+				"server_type":     "cpx22",
+				"location":        "nbg1",
+				"architecture":    "x86",
+
+				"intermediate_snapshot_ids": []int64{167438587},
+				"replicated_snapshot_ids":   map[string]int64{"staging": 167438591},
+			},
+			replicas: []*Artifact{{snapshotId: 167438591}},
+		},
+	})
+
+	assert.Equal(t, "arm:167438588,x86:167438589", artifact.Id())
+	assert.Equal(t, "Snapshots were created: 'test-image' (IDs: arm: 167438588, x86: 167438589), intermediate snapshots were created (IDs: 167438587)", artifact.String())
+	assert.Equal(t, map[string]int64{"arm": 167438588, "x86": 167438589}, artifact.State("snapshot_ids"))
+	assert.Equal(t, "arm", artifact.State("architecture"))
+	assert.Equal(t, []int64{167438587}, artifact.State("intermediate_snapshot_ids"))
+	assert.Equal(t, map[string]map[string]int64{
+		"staging": {"arm": 167438590, "x86": 167438591},
+	}, artifact.State("replicated_snapshot_ids"))
+	assert.Len(t, artifact.replicas, 2)
+
+	result := artifact.State(registryimage.ArtifactStateURI)
+	require.NotNil(t, result)
+
+	var images []registryimage.Image
+	if err := mapstructure.Decode(result, &images); err != nil {
+		t.Errorf("unexpected error when trying to decode state into []registryimage.Image %v", err)
+	}
+
+	assert.Equal(t, []registryimage.Image{
+		{
+			ImageID:        "167438588",
+			ProviderName:   "hetznercloud",
+			ProviderRegion: "fsn1",
+			SourceImageID:  "161547270",
+			Labels: map[string]string{
+				"source_image": "ubuntu-24.04",
+				"server_type":  "cax11",
+				"architecture": "arm",
+			},
+		},
+		{
+			ImageID:        "167438589",
+			ProviderName:   "hetznercloud",
+			ProviderRegion: "nbg1",
+			SourceImageID:  "161547269",
+			Labels: map[string]string{
+				"source_image": "ubuntu-24.04",
+				"server_type":  "cpx22",
+				"architecture": "x86",
+			},
+		},
+	}, images)
+}
+
+func TestArtifactDestroy_protected(t *testing.T) {
+	server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+		{
+			Method: "POST", Path: "/images/42/actions/change_protection",
+			Status: 201,
+			JSONRaw: `{
+				"action": { "id": 4, "status": "success" }
+			}`,
+		},
+		{
+			Method: "DELETE", Path: "/images/42",
+			Status: 204,
+		},
+	}))
+	defer server.Close()
+
+	artifact := &Artifact{
+		snapshotId:   42,
+		snapshotName: "packer-foobar",
+		hcloudClient: hcloud.NewClient(hcloud.WithEndpoint(server.URL)),
+		StateData:    map[string]interface{}{"protected": true},
+	}
+	require.NoError(t, artifact.Destroy())
+}
+
+func TestArtifactDestroy_intermediateSnapshots(t *testing.T) {
+	server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+		{
+			Method: "DELETE", Path: "/images/42",
+			Status: 204,
+		},
+		{
+			Method: "DELETE", Path: "/images/40",
+			Status: 204,
+		},
+		{
+			Method: "DELETE", Path: "/images/41",
+			Status: 204,
+		},
+	}))
+	defer server.Close()
+
+	artifact := &Artifact{
+		snapshotId:   42,
+		snapshotName: "packer-foobar",
+		hcloudClient: hcloud.NewClient(hcloud.WithEndpoint(server.URL)),
+		StateData:    map[string]interface{}{"intermediate_snapshot_ids": []int64{40, 41}},
+	}
+	require.NoError(t, artifact.Destroy())
+}
+
+func TestArtifactDestroy_replicas(t *testing.T) {
+	server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+		{
+			Method: "DELETE", Path: "/images/42",
+			Status: 204,
+		},
+	}))
+	defer server.Close()
+
+	// The replica is deleted with the client of its project
+	replicaServer := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+		{
+			Method: "POST", Path: "/images/43/actions/change_protection",
+			Status: 201,
+			JSONRaw: `{
+				"action": { "id": 4, "status": "success" }
+			}`,
+		},
+		{
+			Method: "DELETE", Path: "/images/43",
+			Status: 204,
+		},
+	}))
+	defer replicaServer.Close()
+
+	artifact := &Artifact{
+		snapshotId:   42,
+		snapshotName: "packer-foobar",
+		hcloudClient: hcloud.NewClient(hcloud.WithEndpoint(server.URL)),
+		StateData:    map[string]interface{}{},
+		replicas: []*Artifact{{
+			snapshotId:   43,
+			snapshotName: "packer-foobar",
+			hcloudClient: hcloud.NewClient(hcloud.WithEndpoint(replicaServer.URL)),
+			StateData:    map[string]interface{}{"protected": true},
+		}},
+	}
+	require.NoError(t, artifact.Destroy())
+}
+
+func TestSnapshotIDsFromArtifact(t *testing.T) {
+	testCases := []struct {
+		name     string
+		artifact packersdk.Artifact
+		want     []int64
+		wantErr  string
+	}{
+		{
+			name:     "single",
+			artifact: &Artifact{snapshotId: 42},
+			want:     []int64{42},
+		},
+		{
+			name: "multiple architectures",
+			artifact: &Artifact{snapshotId: 42, StateData: map[string]interface{}{
+				"snapshot_ids": map[string]int64{"x86": 42, "arm": 43},
+			}},
+			want: []int64{43, 42},
+		},
+		{
+			name:     "unsupported artifact",
+			artifact: &packersdk.MockArtifact{BuilderIdValue: "other", IdValue: "42"},
+			wantErr:  `unsupported artifact type "other"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := SnapshotIDsFromArtifact(tc.artifact)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, result)
+		})
+	}
+}
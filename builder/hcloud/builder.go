@@ -6,7 +6,6 @@ package hcloud
 import (
 	"context"
 	"fmt"
-	"log"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
@@ -15,7 +14,6 @@ import (
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
-	"github.com/hetznercloud/packer-plugin-hcloud/version"
 )
 
 // The unique id for the builder
@@ -25,6 +23,9 @@ type Builder struct {
 	config       Config
 	runner       multistep.Runner
 	hcloudClient *hcloud.Client
+
+	// export skips the steps preparing the server for the snapshot
+	export bool
 }
 
 func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
@@ -35,23 +36,75 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, warnings, errs
 	}
 
-	return nil, nil, nil
+	generatedData := []string{"Location", "ServerID", "SSHKeyID", "SSHHostKeyPinned"}
+
+	return generatedData, nil, nil
 }
 
 func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook) (packersdk.Artifact, error) {
-	opts := []hcloud.ClientOption{
-		hcloud.WithToken(b.config.HCloudToken),
-		hcloud.WithEndpoint(b.config.Endpoint),
-		hcloud.WithPollOpts(hcloud.PollOpts{BackoffFunc: hcloud.ConstantBackoff(b.config.PollInterval)}),
-		hcloud.WithApplication("hcloud-packer", version.PluginVersion.String()),
-
-		// This is being redirect by Packer to the appropriate location. If users set `PACKER_LOG=1` it is shown on stderr
-		hcloud.WithDebugWriter(log.Writer()),
+	b.hcloudClient = b.config.NewClient()
+
+	if len(b.config.Architectures) == 0 {
+		artifact, err := b.run(ctx, ui, hook, &b.config, &commonsteps.StepProvision{})
+		if artifact == nil {
+			return nil, err
+		}
+		return artifact, err
+	}
+
+	// Build the image for each architecture, one after the other
+	var artifacts []*Artifact
+	for _, architecture := range b.config.architectures() {
+		config := b.config.architectureConfig(architecture)
+
+		ui.Say(fmt.Sprintf("Building for architecture %s using server type %s...", architecture, config.ServerType))
+		artifact, err := b.run(ctx, ui, hook, &config, &commonsteps.StepProvision{})
+		if err != nil {
+			for _, artifact := range artifacts {
+				ui.Say(fmt.Sprintf("Deleting snapshot with ID: %s", artifact.Id()))
+				if err := artifact.Destroy(); err != nil {
+					ui.Error(fmt.Sprintf("Could not delete snapshot (please delete it manually): %s", err))
+				}
+			}
+			return nil, err
+		}
+		if artifact != nil {
+			artifacts = append(artifacts, artifact)
+		}
 	}
-	b.hcloudClient = hcloud.NewClient(opts...)
+
+	if len(artifacts) == 0 {
+		return nil, nil
+	}
+	return mergeArtifacts(artifacts), nil
+}
+
+// Import creates a snapshot from a server, running the given step in place of
+// the provisioners. The config must have been prepared. It is used by the
+// hcloud-import post-processor, to write a disk image from rescue mode.
+func Import(ctx context.Context, ui packersdk.Ui, config *Config, step multistep.Step) (*Artifact, error) {
+	b := &Builder{config: *config}
+	b.hcloudClient = b.config.NewClient()
+
+	return b.run(ctx, ui, nil, &b.config, step)
+}
+
+// Export boots a server, running the given step in place of the provisioners,
+// without creating a snapshot. The config must have been prepared. It is used
+// by the hcloud-export post-processor, to read the disk from rescue mode.
+func Export(ctx context.Context, ui packersdk.Ui, config *Config, step multistep.Step) error {
+	b := &Builder{config: *config, export: true}
+	b.config.SkipCreateSnapshot = true
+	b.hcloudClient = b.config.NewClient()
+
+	_, err := b.run(ctx, ui, nil, &b.config, step)
+	return err
+}
+
+func (b *Builder) run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook, config *Config, provision multistep.Step) (*Artifact, error) {
 	// Set up the state
 	state := new(multistep.BasicStateBag)
-	state.Put(StateConfig, &b.config)
+	state.Put(StateConfig, config)
 	state.Put(StateHCloudClient, b.hcloudClient)
 	state.Put(StateHook, hook)
 	state.Put(StateUI, ui)
@@ -59,36 +112,81 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 	// Build the steps
 	steps := []multistep.Step{
 		&stepPreValidate{
-			Force:        b.config.PackerForce,
-			SnapshotName: b.config.SnapshotName,
-		},
-		&communicator.StepSSHKeyGen{
-			CommConf:            &b.config.Comm,
-			SSHTemporaryKeyPair: b.config.Comm.SSH.SSHTemporaryKeyPair,
+			Force:        config.PackerForce,
+			SnapshotName: config.SnapshotName,
 		},
-		multistep.If(b.config.PackerDebug && b.config.Comm.SSHPrivateKeyFile == "",
+		multistep.If(config.needsSSHKey(),
+			&communicator.StepSSHKeyGen{
+				CommConf:            &config.Comm,
+				SSHTemporaryKeyPair: config.Comm.SSH.SSHTemporaryKeyPair,
+			},
+		),
+		multistep.If(config.needsSSHKey() && config.PackerDebug && config.Comm.SSHPrivateKeyFile == "",
 			&communicator.StepDumpSSHKey{
-				Path: fmt.Sprintf("ssh_key_%s.pem", b.config.PackerBuildName),
-				SSH:  &b.config.Comm.SSH,
+				Path: fmt.Sprintf("ssh_key_%s.pem", config.PackerBuildName),
+				SSH:  &config.Comm.SSH,
 			},
 		),
-		&stepCreateSSHKey{},
+		multistep.If(config.needsSSHKey(),
+			&stepCreateSSHKey{},
+		),
 		&stepCreateServer{},
-		&communicator.StepConnect{
-			Config:    &b.config.Comm,
-			Host:      getServerIP,
-			SSHConfig: b.config.Comm.SSHConfigFunc(),
-		},
-		&commonsteps.StepProvision{},
+		multistep.If(config.PackerDebug,
+			&stepVNCProxy{},
+		),
+		multistep.If(config.DiskImageURL != "",
+			&stepWriteDiskImage{},
+		),
+		multistep.If(config.RescueBefore != nil,
+			&stepRescuePhase{Name: "rescue_before", Phase: config.RescueBefore, Leave: true},
+		),
+		multistep.If(len(config.BootCommand) > 0,
+			&stepTypeBootCommand{},
+		),
+		multistep.If(config.ResetRootPassword,
+			&stepResetRootPassword{},
+		),
+		multistep.If(config.Comm.Type != "none",
+			&communicator.StepConnect{
+				Config:    &config.Comm,
+				Host:      getServerIP,
+				SSHConfig: sshConfigFunc(config),
+			},
+		),
+		multistep.If(config.WaitForCloudInit,
+			&stepWaitForCloudInit{},
+		),
+		multistep.If(config.Comm.Type == "none",
+			&stepWaitForGuestPowerOff{},
+		),
+		provision,
 		&commonsteps.StepCleanupTempKeys{
-			Comm: &b.config.Comm,
+			Comm: &config.Comm,
 		},
-		&stepShutdownServer{},
+		multistep.If(config.PinSSHHostKey,
+			&stepRemoveSSHHostKey{},
+		),
+		multistep.If(config.RescueAfter != nil,
+			&stepRescuePhase{Name: "rescue_after", Phase: config.RescueAfter},
+		),
+		multistep.If(config.SnapshotMode == SnapshotModeLive,
+			&stepFreezeFilesystems{},
+		),
+		multistep.If(config.SnapshotMode != SnapshotModeLive && config.Comm.Type != "none" && !b.export,
+			&stepShutdownServer{},
+		),
+		multistep.If(config.ISO != "",
+			&stepDetachISO{},
+		),
 		&stepCreateSnapshot{},
+		multistep.If(len(config.ReplicateTo) > 0,
+			&stepReplicateSnapshot{},
+		),
 	}
 	// Run the steps
-	b.runner = commonsteps.NewRunner(steps, b.config.PackerConfig, ui)
+	b.runner = commonsteps.NewRunner(steps, config.PackerConfig, ui)
 	b.runner.Run(ctx, state)
+	collectIntermediateSnapshots(state)
 	// If there was an error, return that
 	if rawErr, ok := state.GetOk(StateError); ok {
 		return nil, rawErr.(error)
@@ -104,11 +202,23 @@ func (b *Builder) Run(ctx context.Context, ui packersdk.Ui, hook packersdk.Hook)
 		hcloudClient: b.hcloudClient,
 		StateData: map[string]interface{}{
 			"generated_data":  state.Get(StateGeneratedData),
-			"source_image":    b.config.Image,
+			"source_image":    config.Image,
 			"source_image_id": state.Get(StateSourceImageID),
-			"server_type":     b.config.ServerType,
+			"server_type":     config.ServerType,
+			"location":        state.Get(StateLocation),
+			"architecture":    string(state.Get(StateServerType).(*hcloud.ServerType).Architecture),
+			"protected":       config.SnapshotProtection,
 		},
 	}
+	if intermediateSnapshotIDs, ok := state.GetOk(StateIntermediateSnapshotIDs); ok {
+		artifact.StateData["intermediate_snapshot_ids"] = intermediateSnapshotIDs
+	}
+	if replicatedSnapshotIDs, ok := state.GetOk(StateReplicatedSnapshotIDs); ok {
+		artifact.StateData["replicated_snapshot_ids"] = replicatedSnapshotIDs
+	}
+	if replicas, ok := state.GetOk(StateReplicas); ok {
+		artifact.replicas = replicas.([]*Artifact)
+	}
 
 	return artifact, nil
 }
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/packer-plugin-hcloud/version"
+)
+
+// ClientConfig holds the options used to access the Hetzner Cloud API. It is
+// shared by all the components of this plugin.
+type ClientConfig struct {
+	HCloudToken string `mapstructure:"token"`
+	Endpoint    string `mapstructure:"endpoint"`
+
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+}
+
+// Prepare sets the defaults of the client options and validates them.
+func (c *ClientConfig) Prepare() []error {
+	if c.HCloudToken == "" {
+		c.HCloudToken = os.Getenv("HCLOUD_TOKEN")
+	}
+	if c.Endpoint == "" {
+		if os.Getenv("HCLOUD_ENDPOINT") != "" {
+			c.Endpoint = os.Getenv("HCLOUD_ENDPOINT")
+		} else {
+			c.Endpoint = hcloud.Endpoint
+		}
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = 500 * time.Millisecond
+	}
+
+	var errs []error
+	if c.HCloudToken == "" {
+		// Required configurations that will display errors if not set
+		errs = append(errs, errors.New("token is missing, make sure to configure your Hetzner Cloud token"))
+	} else {
+		packersdk.LogSecretFilter.Set(c.HCloudToken)
+	}
+	return errs
+}
+
+// NewClient returns a new Hetzner Cloud API client using the client options.
+func (c *ClientConfig) NewClient() *hcloud.Client {
+	opts := []hcloud.ClientOption{
+		hcloud.WithToken(c.HCloudToken),
+		hcloud.WithEndpoint(c.Endpoint),
+		hcloud.WithPollOpts(hcloud.PollOpts{BackoffFunc: hcloud.ConstantBackoff(c.PollInterval)}),
+		hcloud.WithApplication("hcloud-packer", version.PluginVersion.String()),
+
+		// This is being redirect by Packer to the appropriate location. If users set `PACKER_LOG=1` it is shown on stderr
+		hcloud.WithDebugWriter(log.Writer()),
+	}
+	return hcloud.NewClient(opts...)
+}
@@ -1,20 +1,30 @@
 // Copyright (c) HashiCorp, Inc.
 // SPDX-License-Identifier: MPL-2.0
 
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,imageFilter
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,imageFilter,replicationTarget,rescuePhase,userDataPart
 
 package hcloud
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
+	"net/url"
 	"os"
+	"path"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/shutdowncommand"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
@@ -23,18 +33,23 @@ import (
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
+const (
+	SnapshotModeShutdown = "shutdown"
+	SnapshotModeLive     = "live"
+)
+
+var diskImageFormats = []string{"raw", "gz", "xz", "bz2", "zst"}
+
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 	Comm                communicator.Config `mapstructure:",squash"`
-
-	HCloudToken string `mapstructure:"token"`
-	Endpoint    string `mapstructure:"endpoint"`
-
-	PollInterval time.Duration `mapstructure:"poll_interval"`
+	ClientConfig        `mapstructure:",squash"`
 
 	ServerName        string            `mapstructure:"server_name"`
 	Location          string            `mapstructure:"location"`
+	Locations         []string          `mapstructure:"locations"`
 	ServerType        string            `mapstructure:"server_type"`
+	Architectures     map[string]string `mapstructure:"architectures"`
 	ServerLabels      map[string]string `mapstructure:"server_labels"`
 	UpgradeServerType string            `mapstructure:"upgrade_server_type"`
 	Image             string            `mapstructure:"image"`
@@ -43,10 +58,21 @@ type Config struct {
 	SkipCreateSnapshot bool              `mapstructure:"skip_create_snapshot"`
 	SnapshotName       string            `mapstructure:"snapshot_name"`
 	SnapshotLabels     map[string]string `mapstructure:"snapshot_labels"`
+	SnapshotProtection bool              `mapstructure:"snapshot_protection"`
 	UserData           string            `mapstructure:"user_data"`
 	UserDataFile       string            `mapstructure:"user_data_file"`
 	SSHKeys            []string          `mapstructure:"ssh_keys"`
 	SSHKeysLabels      map[string]string `mapstructure:"ssh_keys_labels"`
+	ResetRootPassword  bool              `mapstructure:"reset_root_password"`
+
+	UserDataParts []userDataPart `mapstructure:"user_data_parts"`
+
+	WaitForCloudInit bool `mapstructure:"wait_for_cloud_init"`
+
+	PinSSHHostKey bool `mapstructure:"pin_ssh_host_key"`
+
+	SnapshotMode         string   `mapstructure:"snapshot_mode"`
+	SnapshotFreezeMounts []string `mapstructure:"snapshot_freeze_mounts"`
 
 	Networks           []int64  `mapstructure:"networks"`
 	PublicIPv4         string   `mapstructure:"public_ipv4"`
@@ -55,11 +81,52 @@ type Config struct {
 	PublicIPv6Disabled bool     `mapstructure:"public_ipv6_disabled"`
 	Firewalls          []string `mapstructure:"firewalls"`
 
-	RescueMode string `mapstructure:"rescue"`
+	RescueMode   string       `mapstructure:"rescue"`
+	RescueBefore *rescuePhase `mapstructure:"rescue_before"`
+	RescueAfter  *rescuePhase `mapstructure:"rescue_after"`
+
+	ReplicateTo []replicationTarget `mapstructure:"replicate_to"`
+
+	ISO         string `mapstructure:"iso"`
+	BootFromISO bool   `mapstructure:"boot_from_iso"`
+
+	DiskImageURL      string `mapstructure:"disk_image_url"`
+	DiskImageChecksum string `mapstructure:"disk_image_checksum"`
+	DiskImageFormat   string `mapstructure:"disk_image_format"`
+
+	bootcommand.BootConfig `mapstructure:",squash"`
+	BootKeyInterval        time.Duration `mapstructure:"boot_key_interval"`
+
+	shutdowncommand.ShutdownConfig `mapstructure:",squash"`
+	Sysprep                        bool `mapstructure:"sysprep"`
+
+	// rescueComm is the communicator of the rescue system.
+	rescueComm communicator.Config
 
 	ctx interpolate.Context
 }
 
+type replicationTarget struct {
+	ClientConfig `mapstructure:",squash"`
+
+	Name           string            `mapstructure:"name"`
+	Location       string            `mapstructure:"location"`
+	ServerType     string            `mapstructure:"server_type"`
+	Image          string            `mapstructure:"image"`
+	SnapshotLabels map[string]string `mapstructure:"snapshot_labels"`
+}
+
+type rescuePhase struct {
+	Inline  []string `mapstructure:"inline"`
+	Scripts []string `mapstructure:"scripts"`
+}
+
+type userDataPart struct {
+	Type    string `mapstructure:"type"`
+	Content string `mapstructure:"content"`
+	File    string `mapstructure:"file"`
+}
+
 type imageFilter struct {
 	WithSelector []string `mapstructure:"with_selector"`
 	MostRecent   bool     `mapstructure:"most_recent"`
@@ -73,7 +140,9 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		InterpolateContext: &c.ctx,
 		InterpolateFilter: &interpolate.RenderFilter{
 			Exclude: []string{
+				"boot_command",
 				"run_command",
+				"user_data_parts",
 			},
 		},
 	}, raws...)
@@ -82,20 +151,6 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	}
 
 	// Defaults
-	if c.HCloudToken == "" {
-		c.HCloudToken = os.Getenv("HCLOUD_TOKEN")
-	}
-	if c.Endpoint == "" {
-		if os.Getenv("HCLOUD_ENDPOINT") != "" {
-			c.Endpoint = os.Getenv("HCLOUD_ENDPOINT")
-		} else {
-			c.Endpoint = hcloud.Endpoint
-		}
-	}
-	if c.PollInterval == 0 {
-		c.PollInterval = 500 * time.Millisecond
-	}
-
 	if c.SnapshotName == "" {
 		def, err := interpolate.Render("packer-{{timestamp}}", nil)
 		if err != nil {
@@ -110,24 +165,71 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		c.ServerName = fmt.Sprintf("packer-%s", uuid.TimeOrderedUUID())
 	}
 
+	if c.SnapshotMode == "" {
+		c.SnapshotMode = SnapshotModeShutdown
+	}
+
+	if c.Comm.Type == "winrm" && c.Comm.WinRMUser == "" {
+		c.Comm.WinRMUser = "Administrator"
+	}
+	if (c.Sysprep || c.Comm.Type == "none") && c.ShutdownTimeout == 0 {
+		// Generalizing or configuring the system takes much longer than a
+		// shutdown
+		c.ShutdownTimeout = 30 * time.Minute
+	}
+
 	var errs *packersdk.MultiError
 	if es := c.Comm.Prepare(&c.ctx); len(es) > 0 {
 		errs = packersdk.MultiErrorAppend(errs, es...)
 	}
-	if c.HCloudToken == "" {
-		// Required configurations that will display errors if not set
-		errs = packersdk.MultiErrorAppend(
-			errs, errors.New("token is missing, make sure to configure your Hetzner Cloud token"))
+
+	// The rescue system is reached over SSH as root, also when the build uses
+	// another communicator, for which the SSH defaults are not applied
+	c.rescueComm = c.Comm
+	c.rescueComm.Type = "ssh"
+	c.rescueComm.SSHUsername = "root"
+	c.rescueComm.SSHPassword = ""
+	c.rescueComm.SSHAgentAuth = false
+	if c.Comm.Type != "ssh" {
+		if es := c.rescueComm.Prepare(&c.ctx); len(es) > 0 {
+			errs = packersdk.MultiErrorAppend(errs, es...)
+		}
+	}
+	if es := c.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
 	}
 
-	if c.Location == "" {
+	if c.Location == "" && len(c.Locations) == 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("location or locations is required"))
+	} else if c.Location != "" && len(c.Locations) > 0 {
 		errs = packersdk.MultiErrorAppend(
-			errs, errors.New("location is required"))
+			errs, errors.New("only one of location or locations can be specified"))
 	}
 
-	if c.ServerType == "" {
+	if c.ServerType == "" && len(c.Architectures) == 0 {
 		errs = packersdk.MultiErrorAppend(
 			errs, errors.New("server type is required"))
+	} else if c.ServerType != "" && len(c.Architectures) > 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("only one of server_type or architectures can be specified"))
+	}
+	for architecture, serverType := range c.Architectures {
+		switch hcloud.Architecture(architecture) {
+		case hcloud.ArchitectureX86, hcloud.ArchitectureARM:
+		default:
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("architectures: unknown architecture %q, must be one of %q or %q",
+					architecture, hcloud.ArchitectureX86, hcloud.ArchitectureARM))
+		}
+		if serverType == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("architectures: server type is required for architecture %q", architecture))
+		}
+	}
+	if len(c.Architectures) > 0 && c.UpgradeServerType != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("upgrade_server_type cannot be used with architectures"))
 	}
 
 	if c.Image == "" && c.ImageFilter == nil {
@@ -154,14 +256,317 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
+	if len(c.UserDataParts) > 0 {
+		if c.UserData != "" || c.UserDataFile != "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("user_data_parts cannot be used with user_data or user_data_file"))
+		}
+
+		partsValid := true
+		for i, part := range c.UserDataParts {
+			if _, ok := userDataPartTypes[part.Type]; !ok {
+				partsValid = false
+				errs = packersdk.MultiErrorAppend(
+					errs, fmt.Errorf("user_data_parts[%d]: type must be one of %s",
+						i, strings.Join(slices.Sorted(maps.Keys(userDataPartTypes)), ", ")))
+			}
+			if (part.Content == "") == (part.File == "") {
+				partsValid = false
+				errs = packersdk.MultiErrorAppend(
+					errs, fmt.Errorf("user_data_parts[%d]: one of content or file is required", i))
+			} else if part.File != "" {
+				if _, err := os.Stat(part.File); err != nil {
+					partsValid = false
+					errs = packersdk.MultiErrorAppend(
+						errs, fmt.Errorf("user_data_parts[%d]: file not found: %s", i, part.File))
+				}
+			}
+		}
+
+		// Render the parts to report template and size errors early, the
+		// location might be different once the server was created
+		if partsValid {
+			location := c.Location
+			if len(c.Locations) > 0 {
+				location = c.Locations[0]
+			}
+			if _, err := renderUserData(c, location, "", nil); err != nil {
+				errs = packersdk.MultiErrorAppend(errs, err)
+			}
+		}
+	}
+
+	if c.BootFromISO {
+		if c.ISO == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("iso is required when boot_from_iso is enabled"))
+		}
+		if c.RescueMode != "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("only one of rescue or boot_from_iso can be specified"))
+		}
+	}
+
+	if c.DiskImageURL != "" {
+		if c.BootFromISO {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("only one of disk_image_url or boot_from_iso can be specified"))
+		}
+		if c.RescueMode == "" {
+			c.RescueMode = "linux64"
+		}
+		if u, err := url.Parse(c.DiskImageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("disk_image_url must be a http or https URL"))
+		}
+		if c.DiskImageFormat == "" {
+			c.DiskImageFormat = diskImageFormatFromURL(c.DiskImageURL)
+		}
+		if !slices.Contains(diskImageFormats, c.DiskImageFormat) {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("disk_image_format must be one of %s", strings.Join(diskImageFormats, ", ")))
+		}
+		if c.DiskImageChecksum == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New(`disk_image_checksum is required when disk_image_url is set, use "none" to skip the verification`))
+		} else if _, _, err := parseDiskImageChecksum(c.DiskImageChecksum); err != nil {
+			errs = packersdk.MultiErrorAppend(errs, err)
+		}
+	} else if c.DiskImageChecksum != "" || c.DiskImageFormat != "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("disk_image_url is required when disk_image_checksum or disk_image_format is set"))
+	}
+
+	if c.ResetRootPassword && c.RescueMode != "" && c.DiskImageURL == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("only one of rescue or reset_root_password can be specified"))
+	}
+
+	for _, rescue := range []struct {
+		name  string
+		phase *rescuePhase
+	}{
+		{"rescue_before", c.RescueBefore},
+		{"rescue_after", c.RescueAfter},
+	} {
+		name, phase := rescue.name, rescue.phase
+		if phase == nil {
+			continue
+		}
+		if c.RescueMode != "" && c.DiskImageURL == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("only one of rescue or %s can be specified", name))
+		}
+		if len(phase.Inline) == 0 && len(phase.Scripts) == 0 {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("%s: inline or scripts is required", name))
+		}
+		for _, script := range phase.Scripts {
+			if _, err := os.Stat(script); err != nil {
+				errs = packersdk.MultiErrorAppend(
+					errs, fmt.Errorf("%s: script not found: %s", name, script))
+			}
+		}
+	}
+
+	names := make(map[string]bool, len(c.ReplicateTo))
+	for i := range c.ReplicateTo {
+		target := &c.ReplicateTo[i]
+		if target.Name == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("replicate_to[%d]: name is required", i))
+		} else if names[target.Name] {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("replicate_to[%d]: name %q is used more than once", i, target.Name))
+		}
+		names[target.Name] = true
+
+		// The token must not fall back to the environment variable, which
+		// belongs to the project of the build
+		if target.HCloudToken == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("replicate_to[%d]: token is required", i))
+		} else {
+			packersdk.LogSecretFilter.Set(target.HCloudToken)
+		}
+		if target.Endpoint == "" {
+			target.Endpoint = c.Endpoint
+		}
+		if target.PollInterval == 0 {
+			target.PollInterval = c.PollInterval
+		}
+	}
+	if len(c.ReplicateTo) > 0 && c.SkipCreateSnapshot {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("replicate_to cannot be used with skip_create_snapshot"))
+	}
+
+	switch c.SnapshotMode {
+	case SnapshotModeShutdown:
+		if len(c.SnapshotFreezeMounts) > 0 {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("snapshot_freeze_mounts can only be used with snapshot_mode live"))
+		}
+	case SnapshotModeLive:
+		if len(c.SnapshotFreezeMounts) > 0 && c.Comm.Type != "ssh" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("snapshot_freeze_mounts can only be used with the ssh communicator"))
+		}
+		// The replication reads the disk from the rescue system, which requires
+		// the server to be shut down
+		if len(c.ReplicateTo) > 0 {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("replicate_to cannot be used with snapshot_mode live"))
+		}
+		// The file systems would be synced or frozen in the rescue system, not
+		// in the system of the snapshot
+		if c.RescueAfter != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("rescue_after cannot be used with snapshot_mode live"))
+		}
+	default:
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("snapshot_mode must be one of %s or %s", SnapshotModeShutdown, SnapshotModeLive))
+	}
+
+	if es := c.ShutdownConfig.Prepare(&c.ctx); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if c.WaitForCloudInit && c.Comm.Type != "ssh" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("wait_for_cloud_init can only be used with the ssh communicator"))
+	}
+
+	if c.PinSSHHostKey {
+		if c.Comm.Type != "ssh" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("pin_ssh_host_key can only be used with the ssh communicator"))
+		}
+		if c.RescueMode != "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("pin_ssh_host_key cannot be used with rescue"))
+		}
+		if c.BootFromISO {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("pin_ssh_host_key cannot be used with boot_from_iso"))
+		}
+		if isMultipartUserData(c.UserData) {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("pin_ssh_host_key cannot be used with a MIME multipart user_data, use user_data_parts instead"))
+		}
+	}
+
+	if c.Comm.Type == "winrm" && c.Comm.WinRMPassword == "" && !c.ResetRootPassword {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("winrm_password or reset_root_password is required with the winrm communicator"))
+	}
+
+	if c.Comm.Type == "none" {
+		if c.SnapshotMode == SnapshotModeLive {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("snapshot_mode live cannot be used without communicator"))
+		}
+		if c.ShutdownCommand != "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("shutdown_command cannot be used without communicator"))
+		}
+		if c.RescueAfter != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("rescue_after cannot be used without communicator"))
+		}
+	}
+
+	if c.Sysprep {
+		if c.Comm.Type == "none" {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("sysprep requires a communicator"))
+		}
+		if c.SnapshotMode == SnapshotModeLive {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("sysprep cannot be used with snapshot_mode live"))
+		}
+		// Sysprep is run through the communicator, which rescue_after replaces
+		// with the connection to the rescue system
+		if c.RescueAfter != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, errors.New("sysprep cannot be used with rescue_after"))
+		}
+	}
+
+	if len(c.BootCommand) > 0 {
+		if es := c.BootConfig.Prepare(&c.ctx); len(es) > 0 {
+			errs = packersdk.MultiErrorAppend(errs, es...)
+		}
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
 	}
 
-	packersdk.LogSecretFilter.Set(c.HCloudToken)
 	return nil, nil
 }
 
+// architectures returns the architectures to build the image for, in a stable
+// order.
+func (c *Config) architectures() []string {
+	return slices.Sorted(maps.Keys(c.Architectures))
+}
+
+// architectureConfig returns the config of the build for the architecture.
+// The servers of the architectures are named after it, as the server of the
+// previous architecture might still be deleting.
+func (c *Config) architectureConfig(architecture string) Config {
+	config := *c
+	config.ServerType = c.Architectures[architecture]
+	config.ServerName = fmt.Sprintf("%s-%s", c.ServerName, architecture)
+	return config
+}
+
+// needsSSHKey reports whether the temporary SSH key is needed, by the
+// communicator or to connect to the rescue system.
+func (c *Config) needsSSHKey() bool {
+	return c.Comm.Type != "none" || c.RescueMode != "" || c.RescueBefore != nil || c.RescueAfter != nil || len(c.ReplicateTo) > 0
+}
+
+// diskImageFormatFromURL returns the compression format of the disk image,
+// detected from the extension of the URL path.
+func diskImageFormatFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".gz":
+		return "gz"
+	case ".xz":
+		return "xz"
+	case ".bz2":
+		return "bz2"
+	case ".zst":
+		return "zst"
+	}
+	return "raw"
+}
+
+// parseDiskImageChecksum parses a checksum in the "<algorithm>:<hex>" format.
+// An empty algorithm is returned for the "none" checksum.
+func parseDiskImageChecksum(checksum string) (string, string, error) {
+	if checksum == "none" {
+		return "", "", nil
+	}
+
+	algorithm, value, _ := strings.Cut(checksum, ":")
+	size, ok := map[string]int{"sha256": sha256.Size, "sha512": sha512.Size}[algorithm]
+	if !ok {
+		return "", "", errors.New(`disk_image_checksum must be in the "sha256:<hex>" or "sha512:<hex>" format, or "none"`)
+	}
+	if decoded, err := hex.DecodeString(value); err != nil || len(decoded) != size {
+		return "", "", fmt.Errorf("disk_image_checksum is not a valid %s checksum", algorithm)
+	}
+	return algorithm, value, nil
+}
+
 func getServerIP(state multistep.StateBag) (string, error) {
 	return state.Get(StateServerIP).(string), nil
 }
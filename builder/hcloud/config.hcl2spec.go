@@ -10,87 +10,111 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion         *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug               *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	Type                      *string           `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string           `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string           `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int              `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string           `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string           `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string           `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string           `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string           `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int              `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string          `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool             `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string          `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string           `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string           `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool             `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string           `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string           `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool             `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool             `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int              `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string           `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int              `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool             `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string           `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string           `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool             `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string           `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string           `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string           `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string           `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int              `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string           `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string           `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string           `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string           `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string          `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string          `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte            `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte            `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string           `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string           `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string           `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool             `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int              `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string           `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool             `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	HCloudToken               *string           `mapstructure:"token" cty:"token" hcl:"token"`
-	Endpoint                  *string           `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
-	PollInterval              *string           `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
-	ServerName                *string           `mapstructure:"server_name" cty:"server_name" hcl:"server_name"`
-	Location                  *string           `mapstructure:"location" cty:"location" hcl:"location"`
-	ServerType                *string           `mapstructure:"server_type" cty:"server_type" hcl:"server_type"`
-	ServerLabels              map[string]string `mapstructure:"server_labels" cty:"server_labels" hcl:"server_labels"`
-	UpgradeServerType         *string           `mapstructure:"upgrade_server_type" cty:"upgrade_server_type" hcl:"upgrade_server_type"`
-	Image                     *string           `mapstructure:"image" cty:"image" hcl:"image"`
-	ImageFilter               *FlatimageFilter  `mapstructure:"image_filter" cty:"image_filter" hcl:"image_filter"`
-	SkipCreateSnapshot        *bool             `mapstructure:"skip_create_snapshot" cty:"skip_create_snapshot" hcl:"skip_create_snapshot"`
-	SnapshotName              *string           `mapstructure:"snapshot_name" cty:"snapshot_name" hcl:"snapshot_name"`
-	SnapshotLabels            map[string]string `mapstructure:"snapshot_labels" cty:"snapshot_labels" hcl:"snapshot_labels"`
-	UserData                  *string           `mapstructure:"user_data" cty:"user_data" hcl:"user_data"`
-	UserDataFile              *string           `mapstructure:"user_data_file" cty:"user_data_file" hcl:"user_data_file"`
-	SSHKeys                   []string          `mapstructure:"ssh_keys" cty:"ssh_keys" hcl:"ssh_keys"`
-	SSHKeysLabels             map[string]string `mapstructure:"ssh_keys_labels" cty:"ssh_keys_labels" hcl:"ssh_keys_labels"`
-	Networks                  []int64           `mapstructure:"networks" cty:"networks" hcl:"networks"`
-	PublicIPv4                *string           `mapstructure:"public_ipv4" cty:"public_ipv4" hcl:"public_ipv4"`
-	PublicIPv4Disabled        *bool             `mapstructure:"public_ipv4_disabled" cty:"public_ipv4_disabled" hcl:"public_ipv4_disabled"`
-	PublicIPv6                *string           `mapstructure:"public_ipv6" cty:"public_ipv6" hcl:"public_ipv6"`
-	PublicIPv6Disabled        *bool             `mapstructure:"public_ipv6_disabled" cty:"public_ipv6_disabled" hcl:"public_ipv6_disabled"`
-	Firewalls                 []string          `mapstructure:"firewalls" cty:"firewalls" hcl:"firewalls"`
-	RescueMode                *string           `mapstructure:"rescue" cty:"rescue" hcl:"rescue"`
+	PackerBuildName           *string                 `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType         *string                 `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion         *string                 `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug               *bool                   `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce               *bool                   `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError             *string                 `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars            map[string]string       `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars       []string                `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	Type                      *string                 `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect        *string                 `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                   *string                 `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                   *int                    `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername               *string                 `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword               *string                 `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName            *string                 `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName   *string                 `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType   *string                 `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits   *int                    `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                []string                `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys    *bool                   `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos               []string                `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile         *string                 `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile        *string                 `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                    *bool                   `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                *string                 `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout            *string                 `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth              *bool                   `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding *bool                   `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts      *int                    `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost            *string                 `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort            *int                    `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth       *bool                   `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername        *string                 `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword        *string                 `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive     *bool                   `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile  *string                 `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile *string                 `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod     *string                 `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost              *string                 `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort              *int                    `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername          *string                 `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword          *string                 `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval      *string                 `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout       *string                 `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels          []string                `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels           []string                `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey              []byte                  `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey             []byte                  `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                 *string                 `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword             *string                 `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                 *string                 `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy              *bool                   `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                 *int                    `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout              *string                 `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL               *bool                   `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure             *bool                   `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM              *bool                   `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	HCloudToken               *string                 `mapstructure:"token" cty:"token" hcl:"token"`
+	Endpoint                  *string                 `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	PollInterval              *string                 `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
+	ServerName                *string                 `mapstructure:"server_name" cty:"server_name" hcl:"server_name"`
+	Location                  *string                 `mapstructure:"location" cty:"location" hcl:"location"`
+	Locations                 []string                `mapstructure:"locations" cty:"locations" hcl:"locations"`
+	ServerType                *string                 `mapstructure:"server_type" cty:"server_type" hcl:"server_type"`
+	Architectures             map[string]string       `mapstructure:"architectures" cty:"architectures" hcl:"architectures"`
+	ServerLabels              map[string]string       `mapstructure:"server_labels" cty:"server_labels" hcl:"server_labels"`
+	UpgradeServerType         *string                 `mapstructure:"upgrade_server_type" cty:"upgrade_server_type" hcl:"upgrade_server_type"`
+	Image                     *string                 `mapstructure:"image" cty:"image" hcl:"image"`
+	ImageFilter               *FlatimageFilter        `mapstructure:"image_filter" cty:"image_filter" hcl:"image_filter"`
+	SkipCreateSnapshot        *bool                   `mapstructure:"skip_create_snapshot" cty:"skip_create_snapshot" hcl:"skip_create_snapshot"`
+	SnapshotName              *string                 `mapstructure:"snapshot_name" cty:"snapshot_name" hcl:"snapshot_name"`
+	SnapshotLabels            map[string]string       `mapstructure:"snapshot_labels" cty:"snapshot_labels" hcl:"snapshot_labels"`
+	SnapshotProtection        *bool                   `mapstructure:"snapshot_protection" cty:"snapshot_protection" hcl:"snapshot_protection"`
+	UserData                  *string                 `mapstructure:"user_data" cty:"user_data" hcl:"user_data"`
+	UserDataFile              *string                 `mapstructure:"user_data_file" cty:"user_data_file" hcl:"user_data_file"`
+	SSHKeys                   []string                `mapstructure:"ssh_keys" cty:"ssh_keys" hcl:"ssh_keys"`
+	SSHKeysLabels             map[string]string       `mapstructure:"ssh_keys_labels" cty:"ssh_keys_labels" hcl:"ssh_keys_labels"`
+	ResetRootPassword         *bool                   `mapstructure:"reset_root_password" cty:"reset_root_password" hcl:"reset_root_password"`
+	UserDataParts             []FlatuserDataPart      `mapstructure:"user_data_parts" cty:"user_data_parts" hcl:"user_data_parts"`
+	WaitForCloudInit          *bool                   `mapstructure:"wait_for_cloud_init" cty:"wait_for_cloud_init" hcl:"wait_for_cloud_init"`
+	PinSSHHostKey             *bool                   `mapstructure:"pin_ssh_host_key" cty:"pin_ssh_host_key" hcl:"pin_ssh_host_key"`
+	SnapshotMode              *string                 `mapstructure:"snapshot_mode" cty:"snapshot_mode" hcl:"snapshot_mode"`
+	SnapshotFreezeMounts      []string                `mapstructure:"snapshot_freeze_mounts" cty:"snapshot_freeze_mounts" hcl:"snapshot_freeze_mounts"`
+	Networks                  []int64                 `mapstructure:"networks" cty:"networks" hcl:"networks"`
+	PublicIPv4                *string                 `mapstructure:"public_ipv4" cty:"public_ipv4" hcl:"public_ipv4"`
+	PublicIPv4Disabled        *bool                   `mapstructure:"public_ipv4_disabled" cty:"public_ipv4_disabled" hcl:"public_ipv4_disabled"`
+	PublicIPv6                *string                 `mapstructure:"public_ipv6" cty:"public_ipv6" hcl:"public_ipv6"`
+	PublicIPv6Disabled        *bool                   `mapstructure:"public_ipv6_disabled" cty:"public_ipv6_disabled" hcl:"public_ipv6_disabled"`
+	Firewalls                 []string                `mapstructure:"firewalls" cty:"firewalls" hcl:"firewalls"`
+	RescueMode                *string                 `mapstructure:"rescue" cty:"rescue" hcl:"rescue"`
+	RescueBefore              *FlatrescuePhase        `mapstructure:"rescue_before" cty:"rescue_before" hcl:"rescue_before"`
+	RescueAfter               *FlatrescuePhase        `mapstructure:"rescue_after" cty:"rescue_after" hcl:"rescue_after"`
+	ReplicateTo               []FlatreplicationTarget `mapstructure:"replicate_to" cty:"replicate_to" hcl:"replicate_to"`
+	ISO                       *string                 `mapstructure:"iso" cty:"iso" hcl:"iso"`
+	BootFromISO               *bool                   `mapstructure:"boot_from_iso" cty:"boot_from_iso" hcl:"boot_from_iso"`
+	DiskImageURL              *string                 `mapstructure:"disk_image_url" cty:"disk_image_url" hcl:"disk_image_url"`
+	DiskImageChecksum         *string                 `mapstructure:"disk_image_checksum" cty:"disk_image_checksum" hcl:"disk_image_checksum"`
+	DiskImageFormat           *string                 `mapstructure:"disk_image_format" cty:"disk_image_format" hcl:"disk_image_format"`
+	BootGroupInterval         *string                 `mapstructure:"boot_keygroup_interval" cty:"boot_keygroup_interval" hcl:"boot_keygroup_interval"`
+	BootWait                  *string                 `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	BootCommand               []string                `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	BootKeyInterval           *string                 `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	ShutdownCommand           *string                 `mapstructure:"shutdown_command" required:"false" cty:"shutdown_command" hcl:"shutdown_command"`
+	ShutdownTimeout           *string                 `mapstructure:"shutdown_timeout" required:"false" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	Sysprep                   *bool                   `mapstructure:"sysprep" cty:"sysprep" hcl:"sysprep"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -167,7 +191,9 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"poll_interval":                &hcldec.AttrSpec{Name: "poll_interval", Type: cty.String, Required: false},
 		"server_name":                  &hcldec.AttrSpec{Name: "server_name", Type: cty.String, Required: false},
 		"location":                     &hcldec.AttrSpec{Name: "location", Type: cty.String, Required: false},
+		"locations":                    &hcldec.AttrSpec{Name: "locations", Type: cty.List(cty.String), Required: false},
 		"server_type":                  &hcldec.AttrSpec{Name: "server_type", Type: cty.String, Required: false},
+		"architectures":                &hcldec.AttrSpec{Name: "architectures", Type: cty.Map(cty.String), Required: false},
 		"server_labels":                &hcldec.AttrSpec{Name: "server_labels", Type: cty.Map(cty.String), Required: false},
 		"upgrade_server_type":          &hcldec.AttrSpec{Name: "upgrade_server_type", Type: cty.String, Required: false},
 		"image":                        &hcldec.AttrSpec{Name: "image", Type: cty.String, Required: false},
@@ -175,10 +201,17 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"skip_create_snapshot":         &hcldec.AttrSpec{Name: "skip_create_snapshot", Type: cty.Bool, Required: false},
 		"snapshot_name":                &hcldec.AttrSpec{Name: "snapshot_name", Type: cty.String, Required: false},
 		"snapshot_labels":              &hcldec.AttrSpec{Name: "snapshot_labels", Type: cty.Map(cty.String), Required: false},
+		"snapshot_protection":          &hcldec.AttrSpec{Name: "snapshot_protection", Type: cty.Bool, Required: false},
 		"user_data":                    &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
 		"user_data_file":               &hcldec.AttrSpec{Name: "user_data_file", Type: cty.String, Required: false},
 		"ssh_keys":                     &hcldec.AttrSpec{Name: "ssh_keys", Type: cty.List(cty.String), Required: false},
 		"ssh_keys_labels":              &hcldec.AttrSpec{Name: "ssh_keys_labels", Type: cty.Map(cty.String), Required: false},
+		"reset_root_password":          &hcldec.AttrSpec{Name: "reset_root_password", Type: cty.Bool, Required: false},
+		"user_data_parts":              &hcldec.BlockListSpec{TypeName: "user_data_parts", Nested: hcldec.ObjectSpec((*FlatuserDataPart)(nil).HCL2Spec())},
+		"wait_for_cloud_init":          &hcldec.AttrSpec{Name: "wait_for_cloud_init", Type: cty.Bool, Required: false},
+		"pin_ssh_host_key":             &hcldec.AttrSpec{Name: "pin_ssh_host_key", Type: cty.Bool, Required: false},
+		"snapshot_mode":                &hcldec.AttrSpec{Name: "snapshot_mode", Type: cty.String, Required: false},
+		"snapshot_freeze_mounts":       &hcldec.AttrSpec{Name: "snapshot_freeze_mounts", Type: cty.List(cty.String), Required: false},
 		"networks":                     &hcldec.AttrSpec{Name: "networks", Type: cty.List(cty.Number), Required: false},
 		"public_ipv4":                  &hcldec.AttrSpec{Name: "public_ipv4", Type: cty.String, Required: false},
 		"public_ipv4_disabled":         &hcldec.AttrSpec{Name: "public_ipv4_disabled", Type: cty.Bool, Required: false},
@@ -186,6 +219,21 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"public_ipv6_disabled":         &hcldec.AttrSpec{Name: "public_ipv6_disabled", Type: cty.Bool, Required: false},
 		"firewalls":                    &hcldec.AttrSpec{Name: "firewalls", Type: cty.List(cty.String), Required: false},
 		"rescue":                       &hcldec.AttrSpec{Name: "rescue", Type: cty.String, Required: false},
+		"rescue_before":                &hcldec.BlockSpec{TypeName: "rescue_before", Nested: hcldec.ObjectSpec((*FlatrescuePhase)(nil).HCL2Spec())},
+		"rescue_after":                 &hcldec.BlockSpec{TypeName: "rescue_after", Nested: hcldec.ObjectSpec((*FlatrescuePhase)(nil).HCL2Spec())},
+		"replicate_to":                 &hcldec.BlockListSpec{TypeName: "replicate_to", Nested: hcldec.ObjectSpec((*FlatreplicationTarget)(nil).HCL2Spec())},
+		"iso":                          &hcldec.AttrSpec{Name: "iso", Type: cty.String, Required: false},
+		"boot_from_iso":                &hcldec.AttrSpec{Name: "boot_from_iso", Type: cty.Bool, Required: false},
+		"disk_image_url":               &hcldec.AttrSpec{Name: "disk_image_url", Type: cty.String, Required: false},
+		"disk_image_checksum":          &hcldec.AttrSpec{Name: "disk_image_checksum", Type: cty.String, Required: false},
+		"disk_image_format":            &hcldec.AttrSpec{Name: "disk_image_format", Type: cty.String, Required: false},
+		"boot_keygroup_interval":       &hcldec.AttrSpec{Name: "boot_keygroup_interval", Type: cty.String, Required: false},
+		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
+		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"shutdown_command":             &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
+		"shutdown_timeout":             &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
+		"sysprep":                      &hcldec.AttrSpec{Name: "sysprep", Type: cty.Bool, Required: false},
 	}
 	return s
 }
@@ -214,3 +262,92 @@ func (*FlatimageFilter) HCL2Spec() map[string]hcldec.Spec {
 	}
 	return s
 }
+
+// FlatreplicationTarget is an auto-generated flat version of replicationTarget.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatreplicationTarget struct {
+	HCloudToken    *string           `mapstructure:"token" cty:"token" hcl:"token"`
+	Endpoint       *string           `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	PollInterval   *string           `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
+	Name           *string           `mapstructure:"name" cty:"name" hcl:"name"`
+	Location       *string           `mapstructure:"location" cty:"location" hcl:"location"`
+	ServerType     *string           `mapstructure:"server_type" cty:"server_type" hcl:"server_type"`
+	Image          *string           `mapstructure:"image" cty:"image" hcl:"image"`
+	SnapshotLabels map[string]string `mapstructure:"snapshot_labels" cty:"snapshot_labels" hcl:"snapshot_labels"`
+}
+
+// FlatMapstructure returns a new FlatreplicationTarget.
+// FlatreplicationTarget is an auto-generated flat version of replicationTarget.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*replicationTarget) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatreplicationTarget)
+}
+
+// HCL2Spec returns the hcl spec of a replicationTarget.
+// This spec is used by HCL to read the fields of replicationTarget.
+// The decoded values from this spec will then be applied to a FlatreplicationTarget.
+func (*FlatreplicationTarget) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"token":           &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"endpoint":        &hcldec.AttrSpec{Name: "endpoint", Type: cty.String, Required: false},
+		"poll_interval":   &hcldec.AttrSpec{Name: "poll_interval", Type: cty.String, Required: false},
+		"name":            &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"location":        &hcldec.AttrSpec{Name: "location", Type: cty.String, Required: false},
+		"server_type":     &hcldec.AttrSpec{Name: "server_type", Type: cty.String, Required: false},
+		"image":           &hcldec.AttrSpec{Name: "image", Type: cty.String, Required: false},
+		"snapshot_labels": &hcldec.AttrSpec{Name: "snapshot_labels", Type: cty.Map(cty.String), Required: false},
+	}
+	return s
+}
+
+// FlatrescuePhase is an auto-generated flat version of rescuePhase.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatrescuePhase struct {
+	Inline  []string `mapstructure:"inline" cty:"inline" hcl:"inline"`
+	Scripts []string `mapstructure:"scripts" cty:"scripts" hcl:"scripts"`
+}
+
+// FlatMapstructure returns a new FlatrescuePhase.
+// FlatrescuePhase is an auto-generated flat version of rescuePhase.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*rescuePhase) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatrescuePhase)
+}
+
+// HCL2Spec returns the hcl spec of a rescuePhase.
+// This spec is used by HCL to read the fields of rescuePhase.
+// The decoded values from this spec will then be applied to a FlatrescuePhase.
+func (*FlatrescuePhase) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"inline":  &hcldec.AttrSpec{Name: "inline", Type: cty.List(cty.String), Required: false},
+		"scripts": &hcldec.AttrSpec{Name: "scripts", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
+
+// FlatuserDataPart is an auto-generated flat version of userDataPart.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatuserDataPart struct {
+	Type    *string `mapstructure:"type" cty:"type" hcl:"type"`
+	Content *string `mapstructure:"content" cty:"content" hcl:"content"`
+	File    *string `mapstructure:"file" cty:"file" hcl:"file"`
+}
+
+// FlatMapstructure returns a new FlatuserDataPart.
+// FlatuserDataPart is an auto-generated flat version of userDataPart.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*userDataPart) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatuserDataPart)
+}
+
+// HCL2Spec returns the hcl spec of a userDataPart.
+// This spec is used by HCL to read the fields of userDataPart.
+// The decoded values from this spec will then be applied to a FlatuserDataPart.
+func (*FlatuserDataPart) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"type":    &hcldec.AttrSpec{Name: "type", Type: cty.String, Required: false},
+		"content": &hcldec.AttrSpec{Name: "content", Type: cty.String, Required: false},
+		"file":    &hcldec.AttrSpec{Name: "file", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,188 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigPrepareRescueCommunicator(t *testing.T) {
+	testCases := []struct {
+		name string
+		raw  map[string]interface{}
+	}{
+		{
+			name: "none",
+			raw: map[string]interface{}{
+				"communicator": "none",
+			},
+		},
+		{
+			name: "winrm",
+			raw: map[string]interface{}{
+				"communicator":   "winrm",
+				"winrm_password": "dummy",
+			},
+		},
+		{
+			name: "ssh",
+			raw: map[string]interface{}{
+				"communicator": "ssh",
+				"ssh_username": "debian",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+			tc.raw["image"] = "debian-12"
+			tc.raw["location"] = "nbg1"
+			tc.raw["server_type"] = "cpx22"
+			tc.raw["disk_image_url"] = "https://example.com/disk.img"
+			tc.raw["disk_image_checksum"] = "none"
+
+			config := &Config{}
+			_, err := config.Prepare(tc.raw)
+			require.NoError(t, err)
+			assert.True(t, config.needsSSHKey())
+
+			assert.Equal(t, "ssh", config.rescueComm.Type)
+			assert.Equal(t, "root", config.rescueComm.SSHUsername)
+			assert.Equal(t, 22, config.rescueComm.SSHPort)
+			assert.Equal(t, 5*time.Minute, config.rescueComm.SSHTimeout)
+
+			// The temporary SSH key is generated once the config was prepared
+			config.Comm.SSHPrivateKey = []byte("private")
+			step := rescueConnectStep(config)
+			assert.Equal(t, []byte("private"), step.Config.SSHPrivateKey)
+			assert.Equal(t, "root", step.Config.SSHUsername)
+		})
+	}
+}
+
+func TestConfigPrepareSysprep(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "with shutdown_command",
+			raw: map[string]interface{}{
+				"shutdown_command": `C:\Windows\System32\Sysprep\sysprep.exe /generalize /oobe /shutdown /quiet /unattend:C:\unattend.xml`,
+			},
+		},
+		{
+			name: "with rescue_after",
+			raw: map[string]interface{}{
+				"rescue_after": map[string]interface{}{"inline": []string{"true"}},
+			},
+			wantErr: "sysprep cannot be used with rescue_after",
+		},
+		{
+			name: "with reset_root_password",
+			raw: map[string]interface{}{
+				"winrm_password":      "",
+				"reset_root_password": true,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"token":          "dummy",
+				"image":          "debian-12",
+				"location":       "nbg1",
+				"server_type":    "cpx22",
+				"communicator":   "winrm",
+				"winrm_password": "dummy",
+				"sysprep":        true,
+			}
+			for key, value := range tc.raw {
+				raw[key] = value
+			}
+
+			config := &Config{}
+			_, err := config.Prepare(raw)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, 30*time.Minute, config.ShutdownTimeout)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigPrepareSnapshotModeLive(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "with snapshot_freeze_mounts",
+			raw: map[string]interface{}{
+				"snapshot_freeze_mounts": []string{"/"},
+			},
+		},
+		{
+			name: "with rescue_after",
+			raw: map[string]interface{}{
+				"rescue_after": map[string]interface{}{"inline": []string{"true"}},
+			},
+			wantErr: "rescue_after cannot be used with snapshot_mode live",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"token":         "dummy",
+				"image":         "debian-12",
+				"location":      "nbg1",
+				"server_type":   "cpx22",
+				"ssh_username":  "root",
+				"snapshot_mode": "live",
+			}
+			for key, value := range tc.raw {
+				raw[key] = value
+			}
+
+			config := &Config{}
+			_, err := config.Prepare(raw)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigArchitectureConfig(t *testing.T) {
+	config := &Config{}
+	_, err := config.Prepare(map[string]interface{}{
+		"token":         "dummy",
+		"image":         "debian-12",
+		"location":      "nbg1",
+		"ssh_username":  "root",
+		"architectures": map[string]string{"x86": "cpx22", "arm": "cax11"},
+	})
+	require.NoError(t, err)
+
+	x86 := config.architectureConfig("x86")
+	arm := config.architectureConfig("arm")
+	assert.Equal(t, "cpx22", x86.ServerType)
+	assert.Equal(t, "cax11", arm.ServerType)
+	assert.Equal(t, config.ServerName+"-x86", x86.ServerName)
+	assert.Equal(t, config.ServerName+"-arm", arm.ServerName)
+	assert.Empty(t, config.ServerType)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// IntermediateSnapshotLabel is the label set by the hcloud-snapshot provisioner
+// on the snapshots it creates, its value is the ID of the build server.
+const IntermediateSnapshotLabel = "packer.io/intermediate-snapshot-of"
+
+// collectIntermediateSnapshots looks up the snapshots created from the server
+// by the hcloud-snapshot provisioner. It is called once all the steps ran, so
+// the snapshots are also found when a provisioner failed. They are referenced
+// by the artifact, or deleted when the build failed or when no snapshot was
+// created.
+func collectIntermediateSnapshots(state multistep.StateBag) {
+	c, ui, client := UnpackState(state)
+
+	serverID, ok := state.GetOk(StateServerID)
+	if !ok {
+		return
+	}
+
+	// The context of the build is done when it was cancelled
+	ctx := context.Background()
+
+	images, err := client.Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+		ListOpts: hcloud.ListOpts{
+			LabelSelector: IntermediateSnapshotLabel + "=" + strconv.FormatInt(serverID.(int64), 10),
+		},
+		Type: []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+	})
+	if err != nil {
+		ui.Error(fmt.Sprintf("Could not list intermediate snapshots (please delete them manually): %s", err))
+		return
+	}
+	if len(images) == 0 {
+		return
+	}
+
+	snapshotIDs := make([]int64, 0, len(images))
+	for _, image := range images {
+		snapshotIDs = append(snapshotIDs, image.ID)
+	}
+	slices.Sort(snapshotIDs)
+
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if cancelled || halted || c.SkipCreateSnapshot {
+		if !cancelled && !halted {
+			ui.Say("No snapshot was created, the intermediate snapshots are not kept")
+		}
+		for _, snapshotID := range snapshotIDs {
+			ui.Say(fmt.Sprintf("Deleting intermediate snapshot: %d", snapshotID))
+			if _, err := client.Image.Delete(ctx, &hcloud.Image{ID: snapshotID}); err != nil {
+				ui.Error(fmt.Sprintf("Could not delete intermediate snapshot %d (please delete it manually): %s", snapshotID, err))
+			}
+		}
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Found %d intermediate snapshots", len(snapshotIDs)))
+	state.Put(StateIntermediateSnapshotIDs, snapshotIDs)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestCollectIntermediateSnapshots(t *testing.T) {
+	testCases := []struct {
+		name            string
+		setupConfigFunc func(*Config)
+		setupStateFunc  func(multistep.StateBag)
+		wantRequests    []mockutil.Request
+		wantSnapshotIDs []int64
+	}{
+		{
+			name: "happy",
+			setupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?label_selector=packer.io%2Fintermediate-snapshot-of%3D8&page=1&per_page=50&type=snapshot",
+					Status: 200,
+					JSONRaw: `{
+						"images": [
+							{ "id": 14, "type": "snapshot", "created_from": { "id": 8, "name": "dummy-server" }},
+							{ "id": 13, "type": "snapshot", "created_from": { "id": 8, "name": "dummy-server" }}
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+			},
+			wantSnapshotIDs: []int64{13, 14},
+		},
+		{
+			name: "none",
+			setupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?label_selector=packer.io%2Fintermediate-snapshot-of%3D8&page=1&per_page=50&type=snapshot",
+					Status: 200,
+					JSONRaw: `{
+						"images": [],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+			},
+		},
+		{
+			name: "build failed",
+			setupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put(multistep.StateHalted, true)
+			},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?label_selector=packer.io%2Fintermediate-snapshot-of%3D8&page=1&per_page=50&type=snapshot",
+					Status: 200,
+					JSONRaw: `{
+						"images": [
+							{ "id": 13, "type": "snapshot", "created_from": { "id": 8, "name": "dummy-server" }}
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+				{Method: "DELETE", Path: "/images/13",
+					Status: 204,
+				},
+			},
+		},
+		{
+			name: "skip create snapshot",
+			setupConfigFunc: func(c *Config) {
+				c.SkipCreateSnapshot = true
+			},
+			setupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?label_selector=packer.io%2Fintermediate-snapshot-of%3D8&page=1&per_page=50&type=snapshot",
+					Status: 200,
+					JSONRaw: `{
+						"images": [
+							{ "id": 14, "type": "snapshot", "created_from": { "id": 8, "name": "dummy-server" }},
+							{ "id": 13, "type": "snapshot", "created_from": { "id": 8, "name": "dummy-server" }}
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+				{Method: "DELETE", Path: "/images/13",
+					Status: 204,
+				},
+				{Method: "DELETE", Path: "/images/14",
+					Status: 204,
+				},
+			},
+		},
+		{
+			name: "no server",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{}
+			if tc.setupConfigFunc != nil {
+				tc.setupConfigFunc(config)
+			}
+
+			server := httptest.NewServer(mockutil.Handler(t, tc.wantRequests))
+			defer server.Close()
+			client := hcloud.NewClient(hcloud.WithEndpoint(server.URL))
+
+			state := NewTestState(t)
+			state.Put(StateConfig, config)
+			state.Put(StateHCloudClient, client)
+			if tc.setupStateFunc != nil {
+				tc.setupStateFunc(state)
+			}
+
+			collectIntermediateSnapshots(state)
+
+			snapshotIDs, ok := state.GetOk(StateIntermediateSnapshotIDs)
+			if tc.wantSnapshotIDs == nil {
+				assert.False(t, ok)
+			} else {
+				assert.Equal(t, tc.wantSnapshotIDs, snapshotIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// reconnectRetryInterval is the interval between the attempts to reach the
+// server through the communicator once it was powered on.
+const reconnectRetryInterval = 5 * time.Second
+
+// GeneratedDataID returns an ID shared by the builder in its generated data,
+// for example the ServerID. Provisioners run in their own process, the data
+// they receive might have been converted to another type.
+func GeneratedDataID(generatedData map[string]interface{}, key string) (int64, bool) {
+	switch value := generatedData[key].(type) {
+	case int64:
+		return value, true
+	case int:
+		return int64(value), true
+	case float64:
+		return int64(value), true
+	case string:
+		id, err := strconv.ParseInt(value, 10, 64)
+		return id, err == nil
+	}
+	return 0, false
+}
+
+// WaitForCommunicator runs a no-op command until it succeeds. The communicator
+// reconnects to the server when a command fails to start. It is used by the
+// provisioners of this plugin, once they powered the server on.
+func WaitForCommunicator(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		cmd := &packersdk.RemoteCmd{Command: "true"}
+		err := cmd.RunWithUi(ctx, comm, ui)
+		if err == nil && cmd.ExitStatus() == 0 {
+			return nil
+		}
+		log.Printf("[DEBUG] Communicator not ready yet: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(reconnectRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshHostKeyPaths are the files of the injected SSH host key on the server.
+var sshHostKeyPaths = []string{
+	"/etc/ssh/ssh_host_ed25519_key",
+	"/etc/ssh/ssh_host_ed25519_key.pub",
+}
+
+// cloudInitUserDataPaths are the files in which cloud-init keeps a copy of the
+// user data, and therefore of the injected SSH host key. They are patterns,
+// /var/lib/cloud/instance links to the directory of the current instance.
+var cloudInitUserDataPaths = []string{
+	"/var/lib/cloud/instances/*/user-data.txt",
+	"/var/lib/cloud/instances/*/user-data.txt.i",
+	"/var/lib/cloud/instances/*/cloud-config.txt",
+	"/var/lib/cloud/instances/*/obj.pkl",
+}
+
+// sshHostKey is an ephemeral SSH host key, injected into the server through
+// cloud-init, so the communicator only accepts this key.
+type sshHostKey struct {
+	privateKey []byte
+	publicKey  ssh.PublicKey
+}
+
+func generateSSHHostKey() (*sshHostKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sshHostKey{privateKey: pem.EncodeToMemory(block), publicKey: publicKey}, nil
+}
+
+// cloudConfig returns the cloud-config installing the host key, cloud-init
+// does not generate other host keys when keys are provided.
+func (k *sshHostKey) cloudConfig() string {
+	var b strings.Builder
+	b.WriteString("#cloud-config\nssh_keys:\n  ed25519_private: |\n")
+	for _, line := range strings.Split(strings.TrimSpace(string(k.privateKey)), "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	b.WriteString("  ed25519_public: " + string(ssh.MarshalAuthorizedKey(k.publicKey)))
+	return b.String()
+}
+
+// sshConfigFunc returns the SSH client configuration of the communicator, only
+// accepting the injected host key when one was generated.
+func sshConfigFunc(c *Config) func(multistep.StateBag) (*ssh.ClientConfig, error) {
+	sshConfig := c.Comm.SSHConfigFunc()
+
+	return func(state multistep.StateBag) (*ssh.ClientConfig, error) {
+		config, err := sshConfig(state)
+		if err != nil {
+			return nil, err
+		}
+
+		if hostKey, ok := state.GetOk(StateSSHHostKey); ok {
+			publicKey := hostKey.(*sshHostKey).publicKey
+			config.HostKeyCallback = ssh.FixedHostKey(publicKey)
+			config.HostKeyAlgorithms = []string{publicKey.Type()}
+		}
+		return config, nil
+	}
+}
@@ -0,0 +1,69 @@
+package hcloud
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSHHostKeyCloudConfig(t *testing.T) {
+	hostKey, err := generateSSHHostKey()
+	require.NoError(t, err)
+
+	cloudConfig := hostKey.cloudConfig()
+	require.True(t, strings.HasPrefix(cloudConfig, "#cloud-config\nssh_keys:\n  ed25519_private: |\n"))
+
+	lines := strings.Split(strings.TrimSuffix(cloudConfig, "\n"), "\n")
+	var privateKey []string
+	for _, line := range lines[3 : len(lines)-1] {
+		require.True(t, strings.HasPrefix(line, "    "))
+		privateKey = append(privateKey, strings.TrimPrefix(line, "    "))
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(strings.Join(privateKey, "\n") + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, hostKey.publicKey.Marshal(), signer.PublicKey().Marshal())
+
+	assert.Equal(t, "  ed25519_public: "+string(ssh.MarshalAuthorizedKey(hostKey.publicKey)), lines[len(lines)-1]+"\n")
+}
+
+func TestSSHConfigFunc(t *testing.T) {
+	hostKey, err := generateSSHHostKey()
+	require.NoError(t, err)
+
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ssh.NewPublicKey(otherPublic)
+	require.NoError(t, err)
+
+	config := &Config{}
+	config.Comm.Type = "ssh"
+	config.Comm.SSHUsername = "root"
+	config.Comm.SSHPassword = "secret"
+
+	addr := &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 22}
+
+	t.Run("not pinned", func(t *testing.T) {
+		sshConfig, err := sshConfigFunc(config)(&multistep.BasicStateBag{})
+		require.NoError(t, err)
+		assert.Empty(t, sshConfig.HostKeyAlgorithms)
+		assert.NoError(t, sshConfig.HostKeyCallback("1.2.3.4:22", addr, otherKey))
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		state := &multistep.BasicStateBag{}
+		state.Put(StateSSHHostKey, hostKey)
+
+		sshConfig, err := sshConfigFunc(config)(state)
+		require.NoError(t, err)
+		assert.Equal(t, []string{ssh.KeyAlgoED25519}, sshConfig.HostKeyAlgorithms)
+		assert.NoError(t, sshConfig.HostKeyCallback("1.2.3.4:22", addr, hostKey.publicKey))
+		assert.Error(t, sshConfig.HostKeyCallback("1.2.3.4:22", addr, otherKey))
+	})
+}
@@ -18,6 +18,7 @@ const (
 
 	StateGeneratedData = "generated_data"
 	StateInstanceID    = "instance_id"
+	StateLocation      = "location"
 	StateServerID      = "server_id"
 	StateServerIP      = "server_ip"
 	StateServerType    = "server_type"
@@ -25,6 +26,13 @@ const (
 	StateSnapshotIDOld = "snapshot_id_old"
 	StateSnapshotName  = "snapshot_name"
 	StateSSHKeyID      = "ssh_key_id"
+	StateSSHHostKey    = "ssh_host_key"
+
+	StateFilesystemsFrozen       = "filesystems_frozen"
+	StateSnapshotOldProtected    = "snapshot_old_protected"
+	StateIntermediateSnapshotIDs = "intermediate_snapshot_ids"
+	StateReplicatedSnapshotIDs   = "replicated_snapshot_ids"
+	StateReplicas                = "replicas"
 
 	StateSourceImageID = "source_image_id"
 )
@@ -5,14 +5,18 @@ package hcloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"image/png"
 	"net/netip"
 	"os"
 	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
@@ -24,7 +28,6 @@ type stepCreateServer struct {
 func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
 	c, ui, client := UnpackState(state)
 
-	sshKeyId := state.Get(StateSSHKeyID).(int64)
 	serverType := state.Get(StateServerType).(*hcloud.ServerType)
 
 	// Create the server based on configuration
@@ -40,7 +43,11 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 		userData = string(contents)
 	}
 
-	sshKeys := []*hcloud.SSHKey{{ID: sshKeyId}}
+	var sshKeys []*hcloud.SSHKey
+	// The temporary SSH key is not created without communicator
+	if sshKeyID, ok := state.GetOk(StateSSHKeyID); ok {
+		sshKeys = append(sshKeys, &hcloud.SSHKey{ID: sshKeyID.(int64)})
+	}
 	for _, idOrName := range c.SSHKeys {
 		sshKey, _, err := client.SSHKey.Get(ctx, idOrName)
 		if err != nil {
@@ -64,6 +71,21 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 		firewalls = append(firewalls, &hcloud.ServerCreateFirewall{Firewall: *firewall})
 	}
 
+	var iso *hcloud.ISO
+	if c.ISO != "" {
+		var err error
+		iso, _, err = client.ISO.Get(ctx, c.ISO)
+		if err != nil {
+			return errorHandler(state, ui, fmt.Sprintf("Could not fetch ISO '%s'", c.ISO), err)
+		}
+		if iso == nil {
+			return errorHandler(state, ui, "", fmt.Errorf("Could not find ISO '%s'", c.ISO))
+		}
+		if iso.Architecture != nil && *iso.Architecture != serverType.Architecture {
+			return errorHandler(state, ui, "", fmt.Errorf("ISO '%s' is not compatible with the server type architecture", c.ISO))
+		}
+	}
+
 	var image *hcloud.Image
 	var err error
 	if c.Image != "" {
@@ -75,7 +97,7 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 			return errorHandler(state, ui, "", fmt.Errorf("Could not find image"))
 		}
 	} else {
-		image, err = getImageWithSelectors(ctx, client, c, serverType)
+		image, err = GetImageWithSelectors(ctx, client, c.ImageFilter.WithSelector, c.ImageFilter.MostRecent, serverType.Architecture)
 		if err != nil {
 			return errorHandler(state, ui, "Could not find image", err)
 		}
@@ -101,7 +123,6 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 		Image:      image,
 		Firewalls:  firewalls,
 		SSHKeys:    sshKeys,
-		Location:   &hcloud.Location{Name: c.Location},
 		UserData:   userData,
 		Networks:   networks,
 		Labels:     c.ServerLabels,
@@ -133,29 +154,87 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 		serverCreateOpts.PublicNet.IPv6 = publicIPv6
 	}
 
-	if c.UpgradeServerType != "" {
+	// The rescue_before phase powers the server on into the rescue system, the
+	// image must not boot before
+	bootIntoRescue := c.RescueBefore != nil && c.DiskImageURL == ""
+
+	if c.UpgradeServerType != "" || c.BootFromISO || bootIntoRescue {
 		serverCreateOpts.StartAfterCreate = hcloud.Ptr(false)
 	}
 
-	serverCreateResult, _, err := client.Server.Create(ctx, serverCreateOpts)
-	if err != nil {
-		return errorHandler(state, ui, "Could not create server", err)
+	var hostKey *sshHostKey
+	if c.PinSSHHostKey {
+		hostKey, err = generateSSHHostKey()
+		if err != nil {
+			return errorHandler(state, ui, "Could not generate SSH host key", err)
+		}
+		state.Put(StateSSHHostKey, hostKey)
 	}
 
-	// We use this in cleanup
-	s.serverId = serverCreateResult.Server.ID
-
-	if err := client.Action.WaitFor(ctx, serverCreateResult.Action); err != nil {
-		return errorHandler(state, ui, "Could not create server", err)
+	locations := c.Locations
+	if len(locations) == 0 {
+		locations = []string{c.Location}
 	}
-	if err := client.Action.WaitFor(ctx, serverCreateResult.NextActions...); err != nil {
-		return errorHandler(state, ui, "Could not create server", err)
+
+	var serverCreateResult hcloud.ServerCreateResult
+	for i, location := range locations {
+		// When the location runs out of capacity, we try to create the server in the
+		// next location
+		hasNextLocation := i < len(locations)-1
+
+		serverCreateOpts.Location = &hcloud.Location{Name: location}
+		if len(c.UserDataParts) > 0 || hostKey != nil {
+			serverCreateOpts.UserData, err = renderUserData(c, location, userData, hostKey)
+			if err != nil {
+				return errorHandler(state, ui, "Could not render user data", err)
+			}
+		}
+		serverCreateResult, _, err = client.Server.Create(ctx, serverCreateOpts)
+		if err != nil {
+			if hasNextLocation && isCapacityError(err) {
+				ui.Say(fmt.Sprintf("Could not create server in location '%s': %s", location, err))
+				continue
+			}
+			return errorHandler(state, ui, "Could not create server", err)
+		}
+
+		// We use this in cleanup
+		s.serverId = serverCreateResult.Server.ID
+
+		if err := client.Action.WaitFor(ctx, serverCreateResult.Action); err != nil {
+			if hasNextLocation && isCapacityError(err) {
+				ui.Say(fmt.Sprintf("Could not create server in location '%s': %s", location, err))
+				if err := deleteServer(ctx, client, s.serverId); err != nil {
+					return errorHandler(state, ui, "Could not destroy server", err)
+				}
+				s.serverId = 0
+				continue
+			}
+			return errorHandler(state, ui, "Could not create server", err)
+		}
+		if err := client.Action.WaitFor(ctx, serverCreateResult.NextActions...); err != nil {
+			return errorHandler(state, ui, "Could not create server", err)
+		}
+
+		// The root password is only returned when no SSH key was injected
+		useRootPassword(c, serverCreateResult.RootPassword)
+
+		if len(locations) > 1 {
+			ui.Say(fmt.Sprintf("Created server in location '%s'", location))
+		}
+		state.Put(StateLocation, location)
+		generatedData := &packerbuilderdata.GeneratedData{State: state}
+		generatedData.Put("Location", location)
+		break
 	}
 
 	// Store server data for later
 	server := serverCreateResult.Server
 
 	state.Put(StateServerID, server.ID)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("ServerID", server.ID)
+	generatedData.Put("SSHHostKeyPinned", hostKey != nil)
 	// instance_id is the generic term used so that users can have access to the
 	// instance id inside of the provisioners, used in step_provision.
 	state.Put(StateInstanceID, server.ID)
@@ -189,7 +268,20 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 		if err := client.Action.WaitFor(ctx, serverChangeTypeAction); err != nil {
 			return errorHandler(state, ui, "Could not upgrade server type", err)
 		}
+	}
+
+	if iso != nil {
+		ui.Say(fmt.Sprintf("Attaching ISO '%s'...", iso.Name))
+		action, _, err := client.Server.AttachISO(ctx, server, iso)
+		if err != nil {
+			return errorHandler(state, ui, "Could not attach ISO", err)
+		}
+		if err := client.Action.WaitFor(ctx, action); err != nil {
+			return errorHandler(state, ui, "Could not attach ISO", err)
+		}
+	}
 
+	if serverCreateOpts.StartAfterCreate != nil && !*serverCreateOpts.StartAfterCreate && !bootIntoRescue {
 		ui.Say("Starting server...")
 		serverPoweronAction, _, err := client.Server.Poweron(ctx, server)
 		if err != nil {
@@ -203,10 +295,15 @@ func (s *stepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 
 	if c.RescueMode != "" {
 		ui.Say("Enabling Rescue Mode...")
-		_, err := setRescue(ctx, client, server, c.RescueMode, sshKeys)
+		rootPassword, err := setRescue(ctx, client, server, c.RescueMode, sshKeys)
 		if err != nil {
 			return errorHandler(state, ui, "Could not enable rescue mode", err)
 		}
+		// The communicator connects to the rescue system, unless a disk image
+		// is written from it
+		if c.DiskImageURL == "" {
+			useRootPassword(c, rootPassword)
+		}
 		ui.Say("Rebooting server...")
 		action, _, err := client.Server.Reset(ctx, server)
 		if err != nil {
@@ -226,7 +323,20 @@ func (s *stepCreateServer) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	_, ui, client := UnpackState(state)
+	c, ui, client := UnpackState(state)
+
+	// Capture the console of the server before destroying it, to help finding
+	// out why the build failed
+	if _, failed := state.GetOk(StateError); failed {
+		path := fmt.Sprintf("screenshot_%s_%d.png", c.ServerName, s.serverId)
+
+		ui.Say("Capturing server console screenshot...")
+		if err := saveScreenshot(client, s.serverId, path); err != nil {
+			ui.Error(fmt.Sprintf("Could not capture server console screenshot: %s", err))
+		} else {
+			ui.Say(fmt.Sprintf("Server console screenshot saved to: %s", path))
+		}
+	}
 
 	// Destroy the server we just created
 	ui.Say("Destroying server...")
@@ -236,6 +346,57 @@ func (s *stepCreateServer) Cleanup(state multistep.StateBag) {
 	}
 }
 
+// isCapacityError returns whether the error was caused by a lack of capacity in
+// the requested location.
+func isCapacityError(err error) bool {
+	codes := []hcloud.ErrorCode{hcloud.ErrorCodeResourceUnavailable, hcloud.ErrorCodePlacementError}
+	if hcloud.IsError(err, codes...) {
+		return true
+	}
+
+	var actionErr hcloud.ActionError
+	if errors.As(err, &actionErr) {
+		return slices.Contains(codes, hcloud.ErrorCode(actionErr.Code))
+	}
+	return false
+}
+
+func deleteServer(ctx context.Context, client *hcloud.Client, serverID int64) error {
+	result, _, err := client.Server.DeleteWithResult(ctx, &hcloud.Server{ID: serverID})
+	if err != nil {
+		return err
+	}
+	return client.Action.WaitFor(ctx, result.Action)
+}
+
+// saveScreenshot captures the VNC console of the server and saves it as a PNG
+// file.
+func saveScreenshot(client *hcloud.Client, serverID int64, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	vnc, err := requestVNC(ctx, client, serverID)
+	if err != nil {
+		return err
+	}
+	defer vnc.Close()
+
+	img, err := vnc.Screenshot(ctx)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 func setRescue(ctx context.Context, client *hcloud.Client, server *hcloud.Server, rescue string, sshKeys []*hcloud.SSHKey) (string, error) {
 	rescueChanged := false
 	if server.RescueEnabled {
@@ -275,14 +436,20 @@ func setRescue(ctx context.Context, client *hcloud.Client, server *hcloud.Server
 	return "", nil
 }
 
-func getImageWithSelectors(ctx context.Context, client *hcloud.Client, c *Config, serverType *hcloud.ServerType) (*hcloud.Image, error) {
+// GetImageWithSelectors returns the available image matching the label
+// selectors. If more than one image matches, the most recent one is returned
+// when mostRecent is set, otherwise an error is returned. An empty architecture
+// matches images of all architectures.
+func GetImageWithSelectors(ctx context.Context, client *hcloud.Client, selectors []string, mostRecent bool, architecture hcloud.Architecture) (*hcloud.Image, error) {
 	var allImages []*hcloud.Image
 
-	selector := strings.Join(c.ImageFilter.WithSelector, ",")
+	selector := strings.Join(selectors, ",")
 	opts := hcloud.ImageListOpts{
-		ListOpts:     hcloud.ListOpts{LabelSelector: selector},
-		Status:       []hcloud.ImageStatus{hcloud.ImageStatusAvailable},
-		Architecture: []hcloud.Architecture{serverType.Architecture},
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+		Status:   []hcloud.ImageStatus{hcloud.ImageStatusAvailable},
+	}
+	if architecture != "" {
+		opts.Architecture = []hcloud.Architecture{architecture}
 	}
 
 	allImages, err := client.Image.AllWithOpts(ctx, opts)
@@ -293,7 +460,7 @@ func getImageWithSelectors(ctx context.Context, client *hcloud.Client, c *Config
 		return nil, fmt.Errorf("no image found for selector %q", selector)
 	}
 	if len(allImages) > 1 {
-		if !c.ImageFilter.MostRecent {
+		if !mostRecent {
 			return nil, fmt.Errorf("more than one image found for selector %q", selector)
 		}
 
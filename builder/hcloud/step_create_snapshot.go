@@ -39,10 +39,26 @@ func (s *stepCreateSnapshot) Run(ctx context.Context, state multistep.StateBag)
 	state.Put(StateSnapshotID, result.Image.ID)
 	state.Put(StateSnapshotName, c.SnapshotName)
 
-	if err := client.Action.WaitFor(ctx, result.Action); err != nil {
+	err = client.Action.WaitFor(ctx, result.Action)
+	// Thaw the file systems frozen for a live snapshot as soon as possible
+	thawFilesystems(state)
+	if err != nil {
 		return errorHandler(state, ui, "Could not create snapshot", err)
 	}
 
+	if c.SnapshotProtection {
+		ui.Say("Enabling snapshot delete protection...")
+		action, _, err := client.Image.ChangeProtection(ctx, result.Image, hcloud.ImageChangeProtectionOpts{
+			Delete: hcloud.Ptr(true),
+		})
+		if err != nil {
+			return errorHandler(state, ui, "Could not enable snapshot protection", err)
+		}
+		if err := client.Action.WaitFor(ctx, action); err != nil {
+			return errorHandler(state, ui, "Could not enable snapshot protection", err)
+		}
+	}
+
 	oldSnap, found := state.GetOk(StateSnapshotIDOld)
 	if !found {
 		return multistep.ActionContinue
@@ -55,6 +71,20 @@ func (s *stepCreateSnapshot) Run(ctx context.Context, state multistep.StateBag)
 	// thus implementing an overwrite semantics.
 	ui.Say(fmt.Sprintf("Deleting old snapshot with ID: %d", oldSnapID))
 	image := &hcloud.Image{ID: oldSnapID}
+
+	// Protected snapshots must be unprotected before they can be deleted
+	if protected, _ := state.Get(StateSnapshotOldProtected).(bool); protected {
+		action, _, err := client.Image.ChangeProtection(ctx, image, hcloud.ImageChangeProtectionOpts{
+			Delete: hcloud.Ptr(false),
+		})
+		if err != nil {
+			return errorHandler(state, ui, fmt.Sprintf("Could not disable protection of old snapshot id=%d", oldSnapID), err)
+		}
+		if err := client.Action.WaitFor(ctx, action); err != nil {
+			return errorHandler(state, ui, fmt.Sprintf("Could not disable protection of old snapshot id=%d", oldSnapID), err)
+		}
+	}
+
 	_, err = client.Image.Delete(ctx, image)
 	if err != nil {
 		return errorHandler(state, ui, fmt.Sprintf("Could not delete old snapshot id=%d", oldSnapID), err)
@@ -58,6 +58,101 @@ func TestStepCreateSnapshot(t *testing.T) {
 				assert.Equal(t, "dummy-snapshot", snapshotName)
 			},
 		},
+		{
+			Name: "happy with frozen file systems",
+			Step: &stepCreateSnapshot{},
+			SetupConfigFunc: func(c *Config) {
+				c.SnapshotMode = SnapshotModeLive
+				c.SnapshotFreezeMounts = []string{"/"}
+				c.Comm.SSHUsername = "root"
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put(StateFilesystemsFrozen, true)
+				state.Put("communicator", &recordingCommunicator{files: map[string]string{}})
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/create_image",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := decodeJSONBody(t, req.Body, &schema.ServerActionCreateImageRequest{})
+						assert.Equal(t, "dummy-snapshot", *payload.Description)
+						assert.Equal(t, "snapshot", *payload.Type)
+					},
+					Status: 201,
+					JSONRaw: `{
+						"image": { "id": 16, "description": "dummy-snapshot", "type": "snapshot" },
+						"action": { "id": 3, "status": "running" }
+					}`,
+				},
+				{
+					Method: "GET", Path: "/actions?id=3&page=1&sort=status&sort=id",
+					Status: 200,
+					JSONRaw: `{
+						"actions": [
+							{ "id": 3, "status": "success" }
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				// The file systems are thawed once the snapshot was created
+				frozen, _ := state.Get(StateFilesystemsFrozen).(bool)
+				assert.False(t, frozen)
+
+				comm := state.Get("communicator").(*recordingCommunicator)
+				assert.Equal(t, []string{"fsfreeze --unfreeze '/'"}, comm.commands)
+			},
+		},
+		{
+			Name: "happy with protection",
+			Step: &stepCreateSnapshot{},
+			SetupConfigFunc: func(c *Config) {
+				c.SnapshotProtection = true
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/create_image",
+					Status: 201,
+					JSONRaw: `{
+						"image": { "id": 16, "description": "dummy-snapshot", "type": "snapshot" },
+						"action": { "id": 3, "status": "running" }
+					}`,
+				},
+				{
+					Method: "GET", Path: "/actions?id=3&page=1&sort=status&sort=id",
+					Status: 200,
+					JSONRaw: `{
+						"actions": [
+							{ "id": 3, "status": "success" }
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+				{
+					Method: "POST", Path: "/images/16/actions/change_protection",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := decodeJSONBody(t, req.Body, &schema.ImageActionChangeProtectionRequest{})
+						assert.True(t, *payload.Delete)
+					},
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 4, "status": "success" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				snapshotID, ok := state.Get(StateSnapshotID).(int64)
+				assert.True(t, ok)
+				assert.Equal(t, int64(16), snapshotID)
+			},
+		},
 		{
 			Name: "fail create image",
 			Step: &stepCreateSnapshot{},
@@ -176,6 +271,65 @@ func TestStepCreateSnapshot(t *testing.T) {
 				assert.Equal(t, "dummy-snapshot", snapshotName)
 			},
 		},
+		{
+			Name: "happy with protected old snapshot",
+			Step: &stepCreateSnapshot{},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put(StateSnapshotIDOld, int64(20))
+				state.Put(StateSnapshotOldProtected, true)
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/create_image",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := decodeJSONBody(t, req.Body, &schema.ServerActionCreateImageRequest{})
+						assert.Equal(t, "dummy-snapshot", *payload.Description)
+						assert.Equal(t, "snapshot", *payload.Type)
+					},
+					Status: 201,
+					JSONRaw: `{
+						"image": { "id": 16, "description": "dummy-snapshot", "type": "snapshot" },
+						"action": { "id": 3, "status": "running" }
+					}`,
+				},
+				{
+					Method: "GET", Path: "/actions?id=3&page=1&sort=status&sort=id",
+					Status: 200,
+					JSONRaw: `{
+						"actions": [
+							{ "id": 3, "status": "success" }
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+				{
+					Method: "POST", Path: "/images/20/actions/change_protection",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := decodeJSONBody(t, req.Body, &schema.ImageActionChangeProtectionRequest{})
+						assert.False(t, *payload.Delete)
+					},
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 4, "status": "success" }
+					}`,
+				},
+				{
+					Method: "DELETE", Path: "/images/20",
+					Status: 204,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				snapshotID, ok := state.Get(StateSnapshotID).(int64)
+				assert.True(t, ok)
+				assert.Equal(t, int64(16), snapshotID)
+
+				snapshotName, ok := state.Get(StateSnapshotName).(string)
+				assert.True(t, ok)
+				assert.Equal(t, "dummy-snapshot", snapshotName)
+			},
+		},
 		{
 			Name: "fail with old snapshot",
 			Step: &stepCreateSnapshot{},
@@ -9,6 +9,7 @@ import (
 	"log"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/hashicorp/packer-plugin-sdk/uuid"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
@@ -47,6 +48,8 @@ func (s *stepCreateSSHKey) Run(ctx context.Context, state multistep.StateBag) mu
 
 	// Remember some state for the future
 	state.Put(StateSSHKeyID, key.ID)
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("SSHKeyID", key.ID)
 
 	return multistep.ActionContinue
 }
@@ -44,6 +44,10 @@ func TestStepCreateSSHKey(t *testing.T) {
 				sshKeyID, ok := state.Get(StateSSHKeyID).(int64)
 				assert.True(t, ok)
 				assert.Equal(t, int64(8), sshKeyID)
+
+				generatedData, ok := state.Get(StateGeneratedData).(map[string]interface{})
+				assert.True(t, ok)
+				assert.Equal(t, int64(8), generatedData["SSHKeyID"])
 			},
 		},
 	})
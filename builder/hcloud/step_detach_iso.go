@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// stepDetachISO detaches the ISO attached by stepCreateServer, so it is not
+// attached to the servers created from the snapshot.
+type stepDetachISO struct{}
+
+func (s *stepDetachISO) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	_, ui, client := UnpackState(state)
+
+	serverID := state.Get(StateServerID).(int64)
+
+	ui.Say("Detaching ISO...")
+
+	action, _, err := client.Server.DetachISO(ctx, &hcloud.Server{ID: serverID})
+	if err != nil {
+		return errorHandler(state, ui, "Could not detach ISO", err)
+	}
+
+	if err := client.Action.WaitFor(ctx, action); err != nil {
+		return errorHandler(state, ui, "Could not detach ISO", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepDetachISO) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// thawTimeout limits the time spent thawing the file systems, a command
+// blocked by a frozen file system must not hang the build.
+const thawTimeout = time.Minute
+
+// stepFreezeFilesystems prepares the running server for a live snapshot, by
+// syncing or freezing its file systems. The file systems are thawed as soon as
+// the snapshot was created, or when the build fails before.
+type stepFreezeFilesystems struct{}
+
+func (s *stepFreezeFilesystems) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, _ := UnpackState(state)
+
+	comm, _ := state.Get("communicator").(packersdk.Communicator)
+	if comm == nil {
+		ui.Say("No communicator available, skipping file systems sync...")
+		return multistep.ActionContinue
+	}
+	if c.Comm.Type != "ssh" {
+		ui.Say("File systems sync is only supported with the ssh communicator, skipping...")
+		return multistep.ActionContinue
+	}
+
+	if len(c.SnapshotFreezeMounts) == 0 {
+		ui.Say("Syncing file systems...")
+		if err := runPrivileged(ctx, ui, comm, c, "sync"); err != nil {
+			return errorHandler(state, ui, "Could not sync file systems", err)
+		}
+		return multistep.ActionContinue
+	}
+
+	commands := []string{"sync"}
+	for _, mount := range c.SnapshotFreezeMounts {
+		commands = append(commands, "fsfreeze --freeze "+shellQuote(mount))
+	}
+
+	ui.Say(fmt.Sprintf("Freezing file systems: %s", strings.Join(c.SnapshotFreezeMounts, ", ")))
+	// Some file systems might be frozen even if the command fails
+	state.Put(StateFilesystemsFrozen, true)
+	if err := runPrivileged(ctx, ui, comm, c, strings.Join(commands, " && ")); err != nil {
+		return errorHandler(state, ui, "Could not freeze file systems", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepFreezeFilesystems) Cleanup(state multistep.StateBag) {
+	thawFilesystems(state)
+}
+
+// thawFilesystems thaws the file systems frozen by stepFreezeFilesystems, if
+// they were not thawed yet.
+func thawFilesystems(state multistep.StateBag) {
+	if frozen, _ := state.Get(StateFilesystemsFrozen).(bool); !frozen {
+		return
+	}
+	state.Put(StateFilesystemsFrozen, false)
+
+	c, ui, _ := UnpackState(state)
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	commands := make([]string, 0, len(c.SnapshotFreezeMounts))
+	for _, mount := range c.SnapshotFreezeMounts {
+		commands = append(commands, "fsfreeze --unfreeze "+shellQuote(mount))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), thawTimeout)
+	defer cancel()
+
+	ui.Say("Thawing file systems...")
+	if err := runPrivileged(ctx, ui, comm, c, strings.Join(commands, "; ")); err != nil {
+		ui.Error(fmt.Sprintf("Could not thaw file systems: %s", err))
+	}
+}
+
+// runPrivileged runs the shell command as root.
+func runPrivileged(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, c *Config, command string) error {
+	cmd := &packersdk.RemoteCmd{Command: privilegedCommand(c, command)}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus() != 0 {
+		return fmt.Errorf("command exited with status %d", cmd.ExitStatus())
+	}
+	return nil
+}
+
+// privilegedCommand wraps the shell command to run it as root, using sudo when
+// the communicator does not connect as root.
+func privilegedCommand(c *Config, command string) string {
+	if c.Comm.SSHUsername != "root" {
+		return "sudo -n sh -c " + shellQuote(command)
+	}
+	return command
+}
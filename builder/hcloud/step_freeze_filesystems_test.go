@@ -0,0 +1,89 @@
+package hcloud
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestStepFreezeFilesystems(t *testing.T) {
+	testCases := []struct {
+		name         string
+		commType     string
+		username     string
+		mounts       []string
+		exitStatus   int
+		wantAction   multistep.StepAction
+		wantCommands []string
+	}{
+		{
+			name:         "sync",
+			username:     "root",
+			wantAction:   multistep.ActionContinue,
+			wantCommands: []string{"sync"},
+		},
+		{
+			name:       "winrm",
+			commType:   "winrm",
+			wantAction: multistep.ActionContinue,
+		},
+		{
+			name:       "freeze",
+			username:   "root",
+			mounts:     []string{"/", "/var/lib/data"},
+			wantAction: multistep.ActionContinue,
+			wantCommands: []string{
+				"sync && fsfreeze --freeze '/' && fsfreeze --freeze '/var/lib/data'",
+				"fsfreeze --unfreeze '/'; fsfreeze --unfreeze '/var/lib/data'",
+			},
+		},
+		{
+			name:       "freeze with sudo",
+			username:   "debian",
+			mounts:     []string{"/"},
+			wantAction: multistep.ActionContinue,
+			wantCommands: []string{
+				`sudo -n sh -c 'sync && fsfreeze --freeze '\''/'\'''`,
+				`sudo -n sh -c 'fsfreeze --unfreeze '\''/'\'''`,
+			},
+		},
+		{
+			name:       "thaw after failure",
+			username:   "root",
+			mounts:     []string{"/"},
+			exitStatus: 1,
+			wantAction: multistep.ActionHalt,
+			wantCommands: []string{
+				"sync && fsfreeze --freeze '/'",
+				"fsfreeze --unfreeze '/'",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{SnapshotMode: SnapshotModeLive, SnapshotFreezeMounts: tc.mounts}
+			config.Comm.Type = "ssh"
+			if tc.commType != "" {
+				config.Comm.Type = tc.commType
+			}
+			config.Comm.SSHUsername = tc.username
+
+			comm := &recordingCommunicator{files: map[string]string{}, exitStatus: tc.exitStatus}
+
+			state := NewTestState(t)
+			state.Put(StateConfig, config)
+			state.Put(StateHCloudClient, hcloud.NewClient())
+			state.Put("communicator", comm)
+
+			step := &stepFreezeFilesystems{}
+			assert.Equal(t, tc.wantAction, step.Run(t.Context(), state))
+			step.Cleanup(state)
+
+			assert.Equal(t, tc.wantCommands, comm.commands)
+		})
+	}
+}
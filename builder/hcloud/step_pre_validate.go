@@ -80,6 +80,7 @@ func (s *stepPreValidate) Run(ctx context.Context, state multistep.StateBag) mul
 			if s.Force {
 				ui.Say(msg + ". Force flag specified, will safely overwrite this snapshot")
 				state.Put(StateSnapshotIDOld, snap.ID)
+				state.Put(StateSnapshotOldProtected, snap.Protection.Delete)
 				return multistep.ActionContinue
 			}
 			return errorHandler(state, ui, "", errors.New(msg))
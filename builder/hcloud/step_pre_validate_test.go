@@ -132,7 +132,7 @@ func TestStepPreValidate(t *testing.T) {
 					Method: "GET", Path: "/images?architecture=x86&page=1&per_page=50&type=snapshot",
 					Status: 200,
 					JSONRaw: `{
-						"images": [{ "id": 1, "description": "dummy-snapshot"}]
+						"images": [{ "id": 1, "description": "dummy-snapshot", "protection": { "delete": true }}]
 					}`,
 				},
 			},
@@ -145,6 +145,10 @@ func TestStepPreValidate(t *testing.T) {
 				snapshotIDOld, ok := state.Get(StateSnapshotIDOld).(int64)
 				assert.True(t, ok)
 				assert.Equal(t, int64(1), snapshotIDOld)
+
+				snapshotOldProtected, ok := state.Get(StateSnapshotOldProtected).(bool)
+				assert.True(t, ok)
+				assert.True(t, snapshotOldProtected)
 			},
 		},
 		{
@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// stepRemoveSSHHostKey removes the injected SSH host key from the server, along
+// with the user data cached by cloud-init, so it is not part of the snapshot.
+// cloud-init generates new host keys on the first boot of servers created from
+// the snapshot.
+type stepRemoveSSHHostKey struct{}
+
+func (s *stepRemoveSSHHostKey) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, _ := UnpackState(state)
+
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	command := "rm -f"
+	for _, path := range sshHostKeyPaths {
+		command += " " + shellQuote(path)
+	}
+	// Not quoted, the patterns are expanded by the shell
+	for _, path := range cloudInitUserDataPaths {
+		command += " " + path
+	}
+
+	ui.Say("Removing the injected SSH host key...")
+	if err := runPrivileged(ctx, ui, comm, c, command); err != nil {
+		return errorHandler(state, ui, "Could not remove the SSH host key", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRemoveSSHHostKey) Cleanup(state multistep.StateBag) {}
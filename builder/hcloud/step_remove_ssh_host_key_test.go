@@ -0,0 +1,62 @@
+package hcloud
+
+import (
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+func TestStepRemoveSSHHostKey(t *testing.T) {
+	testCases := []struct {
+		name         string
+		username     string
+		exitStatus   int
+		wantAction   multistep.StepAction
+		wantCommands []string
+	}{
+		{
+			name:       "root",
+			username:   "root",
+			wantAction: multistep.ActionContinue,
+			wantCommands: []string{"rm -f '/etc/ssh/ssh_host_ed25519_key' '/etc/ssh/ssh_host_ed25519_key.pub'" +
+				" /var/lib/cloud/instances/*/user-data.txt /var/lib/cloud/instances/*/user-data.txt.i /var/lib/cloud/instances/*/cloud-config.txt /var/lib/cloud/instances/*/obj.pkl"},
+		},
+		{
+			name:       "sudo",
+			username:   "debian",
+			wantAction: multistep.ActionContinue,
+			wantCommands: []string{`sudo -n sh -c 'rm -f '\''/etc/ssh/ssh_host_ed25519_key'\'' '\''/etc/ssh/ssh_host_ed25519_key.pub'\''` +
+				` /var/lib/cloud/instances/*/user-data.txt /var/lib/cloud/instances/*/user-data.txt.i /var/lib/cloud/instances/*/cloud-config.txt /var/lib/cloud/instances/*/obj.pkl'`},
+		},
+		{
+			name:       "failure",
+			username:   "root",
+			exitStatus: 1,
+			wantAction: multistep.ActionHalt,
+			wantCommands: []string{"rm -f '/etc/ssh/ssh_host_ed25519_key' '/etc/ssh/ssh_host_ed25519_key.pub'" +
+				" /var/lib/cloud/instances/*/user-data.txt /var/lib/cloud/instances/*/user-data.txt.i /var/lib/cloud/instances/*/cloud-config.txt /var/lib/cloud/instances/*/obj.pkl"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{PinSSHHostKey: true}
+			config.Comm.Type = "ssh"
+			config.Comm.SSHUsername = tc.username
+
+			comm := &recordingCommunicator{files: map[string]string{}, exitStatus: tc.exitStatus}
+
+			state := NewTestState(t)
+			state.Put(StateConfig, config)
+			state.Put(StateHCloudClient, hcloud.NewClient())
+			state.Put("communicator", comm)
+
+			step := &stepRemoveSSHHostKey{}
+			assert.Equal(t, tc.wantAction, step.Run(t.Context(), state))
+			assert.Equal(t, tc.wantCommands, comm.commands)
+		})
+	}
+}
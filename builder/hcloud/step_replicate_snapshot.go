@@ -0,0 +1,225 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const receiveDiskScriptPath = "/tmp/packer-replicate.sh"
+
+// defaultReplicationImage is the image used to create the servers in the
+// projects the snapshot is replicated to, it is replaced by the snapshot disk.
+const defaultReplicationImage = "ubuntu-24.04"
+
+// stepReplicateSnapshot replicates the snapshot into other projects. The build
+// server is booted in rescue mode, and its disk is streamed to a server booted
+// in rescue mode in each project, from which a snapshot is created.
+type stepReplicateSnapshot struct {
+	// connect connects to the rescue system, defaults to rescueConnectStep.
+	connect multistep.Step
+	// importSnapshot creates the snapshot in a project, defaults to Import.
+	importSnapshot func(context.Context, packersdk.Ui, *Config, multistep.Step) (*Artifact, error)
+}
+
+func (s *stepReplicateSnapshot) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, client := UnpackState(state)
+
+	server := &hcloud.Server{ID: state.Get(StateServerID).(int64)}
+	sshKeys := []*hcloud.SSHKey{{ID: state.Get(StateSSHKeyID).(int64)}}
+
+	ui.Say("Enabling Rescue Mode...")
+	if _, err := setRescue(ctx, client, server, "linux64", sshKeys); err != nil {
+		return errorHandler(state, ui, "Could not enable rescue mode", err)
+	}
+
+	ui.Say("Starting server...")
+	action, _, err := client.Server.Poweron(ctx, server)
+	if err != nil {
+		return errorHandler(state, ui, "Could not start server", err)
+	}
+	if err := client.Action.WaitFor(ctx, action); err != nil {
+		return errorHandler(state, ui, "Could not start server", err)
+	}
+
+	ui.Say("Connecting to rescue system...")
+	if s.connect == nil {
+		s.connect = rescueConnectStep(c)
+	}
+	if action := s.connect.Run(ctx, state); action != multistep.ActionContinue {
+		return action
+	}
+	source := state.Get("communicator").(packersdk.Communicator)
+
+	var artifacts []*Artifact
+	replicatedSnapshotIDs := make(map[string]int64, len(c.ReplicateTo))
+	for _, target := range c.ReplicateTo {
+		ui.Say(fmt.Sprintf("Replicating snapshot to project %s...", target.Name))
+
+		artifact, err := s.replicateSnapshot(ctx, ui, c, target, state.Get(StateLocation).(string), source)
+		if err != nil {
+			for _, artifact := range artifacts {
+				ui.Say(fmt.Sprintf("Deleting replicated snapshot with ID: %s", artifact.Id()))
+				if err := artifact.Destroy(); err != nil {
+					ui.Error(fmt.Sprintf("Could not delete replicated snapshot (please delete it manually): %s", err))
+				}
+			}
+			return errorHandler(state, ui, fmt.Sprintf("Could not replicate snapshot to project %s", target.Name), err)
+		}
+
+		artifacts = append(artifacts, artifact)
+		replicatedSnapshotIDs[target.Name] = artifact.snapshotId
+	}
+	state.Put(StateReplicatedSnapshotIDs, replicatedSnapshotIDs)
+	state.Put(StateReplicas, artifacts)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepReplicateSnapshot) Cleanup(state multistep.StateBag) {
+	if s.connect != nil {
+		s.connect.Cleanup(state)
+	}
+}
+
+// replicateSnapshot creates a server booted in rescue mode in the target
+// project, writes the disk of the source to it, and creates a snapshot.
+func (s *stepReplicateSnapshot) replicateSnapshot(ctx context.Context, ui packersdk.Ui, c *Config, target replicationTarget, location string, source packersdk.Communicator) (*Artifact, error) {
+	if target.Location != "" {
+		location = target.Location
+	}
+	serverType := c.ServerType
+	if target.ServerType != "" {
+		serverType = target.ServerType
+	}
+	image := defaultReplicationImage
+	if target.Image != "" {
+		image = target.Image
+	}
+	snapshotLabels := c.SnapshotLabels
+	if target.SnapshotLabels != nil {
+		snapshotLabels = target.SnapshotLabels
+	}
+
+	raw := map[string]interface{}{
+		"packer_build_name":   c.PackerBuildName,
+		"packer_debug":        c.PackerDebug,
+		"packer_force":        c.PackerForce,
+		"token":               target.HCloudToken,
+		"endpoint":            target.Endpoint,
+		"poll_interval":       target.PollInterval,
+		"location":            location,
+		"server_type":         serverType,
+		"image":               image,
+		"server_labels":       c.ServerLabels,
+		"snapshot_name":       c.SnapshotName,
+		"snapshot_labels":     snapshotLabels,
+		"snapshot_protection": c.SnapshotProtection,
+		"rescue":              "linux64",
+		"communicator":        "ssh",
+		"ssh_username":        "root",
+	}
+	targetConfig := &Config{}
+	if _, err := targetConfig.Prepare(raw); err != nil {
+		return nil, err
+	}
+
+	importSnapshot := s.importSnapshot
+	if importSnapshot == nil {
+		importSnapshot = Import
+	}
+	artifact, err := importSnapshot(ctx, ui, targetConfig, &stepReceiveDisk{Source: source, Disk: rescueDisk})
+	if err != nil {
+		return nil, err
+	}
+	if artifact == nil {
+		return nil, errors.New("no snapshot was created")
+	}
+	return artifact, nil
+}
+
+// stepReceiveDisk streams the disk of the source server to the disk of the
+// server, both booted in rescue mode. The data is compressed during the
+// transfer, the integrity of the stream is verified by gzip.
+type stepReceiveDisk struct {
+	Source packersdk.Communicator
+	Disk   string
+}
+
+func (s *stepReceiveDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get(StateUI).(packersdk.Ui)
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	if err := receiveDisk(ctx, ui, s.Source, comm, s.Disk); err != nil {
+		return errorHandler(state, ui, "Could not copy disk", err)
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepReceiveDisk) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
+
+func receiveDisk(ctx context.Context, ui packersdk.Ui, source, target packersdk.Communicator, disk string) error {
+	script := fmt.Sprintf("set -euo pipefail\ngzip -dc | dd of=%s bs=4M conv=fsync status=none\nsync\n", disk)
+	if err := target.Upload(receiveDiskScriptPath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("could not upload script: %w", err)
+	}
+
+	reader, writer := io.Pipe()
+
+	ui.Say(fmt.Sprintf("Copying disk %s...", disk))
+	targetStderr := new(bytes.Buffer)
+	targetCmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("bash %s", receiveDiskScriptPath),
+		Stdin:   reader,
+		Stderr:  targetStderr,
+	}
+	if err := target.Start(ctx, targetCmd); err != nil {
+		return err
+	}
+
+	// Stop the source if the target stops reading early
+	targetDone := make(chan struct{})
+	go func() {
+		defer close(targetDone)
+		targetCmd.Wait()
+		reader.Close()
+	}()
+
+	sourceStderr := new(bytes.Buffer)
+	sourceCmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("gzip -1 -c <%s", disk),
+		Stdout:  writer,
+		Stderr:  sourceStderr,
+	}
+	if err := source.Start(ctx, sourceCmd); err != nil {
+		writer.CloseWithError(err)
+		<-targetDone
+		return err
+	}
+	sourceCmd.Wait()
+	writer.Close()
+	<-targetDone
+
+	if sourceCmd.ExitStatus() != 0 {
+		return fmt.Errorf("reading the disk exited with status %d: %s",
+			sourceCmd.ExitStatus(), strings.TrimSpace(sourceStderr.String()))
+	}
+	if targetCmd.ExitStatus() != 0 {
+		return fmt.Errorf("writing the disk exited with status %d: %s",
+			targetCmd.ExitStatus(), strings.TrimSpace(targetStderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package hcloud
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/packer-plugin-hcloud/internal/testutil"
+)
+
+func TestReceiveDisk(t *testing.T) {
+	for _, name := range []string{"bash", "gzip"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is required", name)
+		}
+	}
+
+	content := bytes.Repeat([]byte("packer"), 1<<20)
+
+	t.Run("success", func(t *testing.T) {
+		// Each server sees its own disk at the same path
+		source := testutil.NewLocalCommunicator(t.TempDir())
+		target := testutil.NewLocalCommunicator(t.TempDir())
+		require.NoError(t, os.WriteFile(filepath.Join(source.Dir, "sda"), content, 0o600))
+
+		err := receiveDisk(context.Background(), &packersdk.MockUi{}, source, target, "sda")
+		require.NoError(t, err)
+
+		written, err := os.ReadFile(filepath.Join(target.Dir, "sda"))
+		require.NoError(t, err)
+		assert.Equal(t, content, written)
+	})
+
+	t.Run("source failure", func(t *testing.T) {
+		source := testutil.NewLocalCommunicator(t.TempDir())
+		target := testutil.NewLocalCommunicator(t.TempDir())
+
+		err := receiveDisk(context.Background(), &packersdk.MockUi{}, source, target, "sda")
+		assert.ErrorContains(t, err, "reading the disk exited with status")
+	})
+}
+
+// stepFakeConnect stands in for the connection to the rescue system.
+type stepFakeConnect struct{}
+
+func (s *stepFakeConnect) Run(_ context.Context, state multistep.StateBag) multistep.StepAction {
+	state.Put("communicator", &packersdk.MockCommunicator{})
+	return multistep.ActionContinue
+}
+
+func (s *stepFakeConnect) Cleanup(multistep.StateBag) {}
+
+// fakeImport validates the target project like the builder does, and returns
+// a snapshot in place of copying the disk.
+func fakeImport(snapshotIDs map[string]int64) func(context.Context, packersdk.Ui, *Config, multistep.Step) (*Artifact, error) {
+	return func(ctx context.Context, ui packersdk.Ui, config *Config, _ multistep.Step) (*Artifact, error) {
+		client := config.NewClient()
+
+		state := new(multistep.BasicStateBag)
+		state.Put(StateConfig, config)
+		state.Put(StateHCloudClient, client)
+		state.Put(StateUI, ui)
+
+		step := &stepPreValidate{Force: config.PackerForce, SnapshotName: config.SnapshotName}
+		if step.Run(ctx, state) != multistep.ActionContinue {
+			return nil, state.Get(StateError).(error)
+		}
+		return &Artifact{
+			snapshotName: config.SnapshotName,
+			snapshotId:   snapshotIDs[config.Endpoint],
+			hcloudClient: client,
+		}, nil
+	}
+}
+
+func TestStepReplicateSnapshot(t *testing.T) {
+	serverTypeRequest := mockutil.Request{
+		Method: "GET", Path: "/server_types?name=cpx22",
+		Status: 200,
+		JSONRaw: `{
+			"server_types": [{ "id": 109, "name": "cpx22", "architecture": "x86" }]
+		}`,
+	}
+	existingSnapshotRequest := mockutil.Request{
+		Method: "GET", Path: "/images?architecture=x86&page=1&per_page=50&type=snapshot",
+		Status: 200,
+		JSONRaw: `{
+			"images": [{ "id": 20, "type": "snapshot", "description": "dummy-snapshot" }]
+		}`,
+	}
+	noSnapshotRequest := mockutil.Request{
+		Method: "GET", Path: "/images?architecture=x86&page=1&per_page=50&type=snapshot",
+		Status: 200,
+		JSONRaw: `{
+			"images": []
+		}`,
+	}
+
+	testCases := []struct {
+		name       string
+		force      bool
+		targets    [2][]mockutil.Request
+		wantAction multistep.StepAction
+		wantError  string
+		wantIDs    map[string]int64
+	}{
+		{
+			name: "happy",
+			targets: [2][]mockutil.Request{
+				{serverTypeRequest, noSnapshotRequest},
+				{serverTypeRequest, noSnapshotRequest},
+			},
+			wantAction: multistep.ActionContinue,
+			wantIDs:    map[string]int64{"staging": 31, "production": 32},
+		},
+		{
+			name:  "happy with force and existing snapshots",
+			force: true,
+			targets: [2][]mockutil.Request{
+				{serverTypeRequest, existingSnapshotRequest},
+				{serverTypeRequest, existingSnapshotRequest},
+			},
+			wantAction: multistep.ActionContinue,
+			wantIDs:    map[string]int64{"staging": 31, "production": 32},
+		},
+		{
+			name: "fail with existing snapshot",
+			targets: [2][]mockutil.Request{
+				{serverTypeRequest, existingSnapshotRequest},
+				nil,
+			},
+			wantAction: multistep.ActionHalt,
+			wantError:  "Could not replicate snapshot to project staging: Found existing snapshot (id=20, arch=x86) with name 'dummy-snapshot'",
+		},
+		{
+			name: "fail with invalid target token",
+			targets: [2][]mockutil.Request{
+				{
+					serverTypeRequest,
+					noSnapshotRequest,
+					// The snapshot replicated to the first project is deleted
+					{Method: "DELETE", Path: "/images/31", Status: 204},
+				},
+				{
+					{Method: "GET", Path: "/server_types?name=cpx22",
+						Status: 401,
+						JSONRaw: `{
+							"error": { "code": "unauthorized", "message": "unable to authenticate" }
+						}`,
+					},
+				},
+			},
+			wantAction: multistep.ActionHalt,
+			wantError:  "Could not replicate snapshot to project production: Could not fetch server type 'cpx22': unable to authenticate (unauthorized",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/enable_rescue",
+					Status: 201,
+					JSONRaw: `{
+						"root_password": "dummy",
+						"action": { "id": 1, "status": "success" }
+					}`,
+				},
+				{Method: "POST", Path: "/servers/8/actions/poweron",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 2, "status": "success" }
+					}`,
+				},
+			}))
+			defer server.Close()
+
+			staging := httptest.NewServer(mockutil.Handler(t, tc.targets[0]))
+			defer staging.Close()
+			production := httptest.NewServer(mockutil.Handler(t, tc.targets[1]))
+			defer production.Close()
+
+			config := &Config{ServerType: "cpx22", SnapshotName: "dummy-snapshot"}
+			config.PackerForce = tc.force
+			config.ReplicateTo = []replicationTarget{
+				{Name: "staging", ClientConfig: ClientConfig{HCloudToken: "staging", Endpoint: staging.URL}},
+				{Name: "production", ClientConfig: ClientConfig{HCloudToken: "production", Endpoint: production.URL}},
+			}
+
+			state := NewTestState(t)
+			state.Put(StateConfig, config)
+			state.Put(StateHCloudClient, hcloud.NewClient(hcloud.WithEndpoint(server.URL)))
+			state.Put(StateServerID, int64(8))
+			state.Put(StateSSHKeyID, int64(1))
+			state.Put(StateLocation, "nbg1")
+
+			step := &stepReplicateSnapshot{
+				connect:        &stepFakeConnect{},
+				importSnapshot: fakeImport(map[string]int64{staging.URL: 31, production.URL: 32}),
+			}
+			assert.Equal(t, tc.wantAction, step.Run(t.Context(), state))
+
+			if tc.wantError != "" {
+				err, ok := state.Get(StateError).(error)
+				require.True(t, ok)
+				assert.ErrorContains(t, err, tc.wantError)
+				return
+			}
+
+			snapshotIDs, ok := state.Get(StateReplicatedSnapshotIDs).(map[string]int64)
+			require.True(t, ok)
+			assert.Equal(t, tc.wantIDs, snapshotIDs)
+
+			replicas, ok := state.Get(StateReplicas).([]*Artifact)
+			require.True(t, ok)
+			require.Len(t, replicas, 2)
+			assert.Equal(t, int64(31), replicas[0].snapshotId)
+			assert.Equal(t, int64(32), replicas[1].snapshotId)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// stepRescuePhase reboots the server into the rescue system and runs the
+// commands of a rescue phase, for example to partition the disk before the
+// first boot, or to shrink the file systems after the provisioning.
+type stepRescuePhase struct {
+	Name  string
+	Phase *rescuePhase
+
+	// Leave reboots the server out of the rescue system once the commands
+	// have run.
+	Leave bool
+
+	connect *communicator.StepConnect
+}
+
+func (s *stepRescuePhase) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, client := UnpackState(state)
+
+	serverID := state.Get(StateServerID).(int64)
+	sshKeys := []*hcloud.SSHKey{{ID: state.Get(StateSSHKeyID).(int64)}}
+
+	ui.Say(fmt.Sprintf("Booting server into rescue mode for %s...", s.Name))
+	if err := enterRescue(ctx, client, serverID, sshKeys); err != nil {
+		return errorHandler(state, ui, "Could not enable rescue mode", err)
+	}
+
+	ui.Say("Connecting to rescue system...")
+	s.connect = rescueConnectStep(c)
+	if action := s.connect.Run(ctx, state); action != multistep.ActionContinue {
+		return action
+	}
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	if err := runRescuePhase(ctx, ui, comm, s.Phase); err != nil {
+		return errorHandler(state, ui, fmt.Sprintf("Could not run %s", s.Name), err)
+	}
+
+	if s.Leave {
+		ui.Say("Rebooting server out of rescue mode...")
+		if err := leaveRescue(ctx, client, serverID); err != nil {
+			return errorHandler(state, ui, "Could not disable rescue mode", err)
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepRescuePhase) Cleanup(state multistep.StateBag) {
+	if s.connect != nil {
+		s.connect.Cleanup(state)
+	}
+}
+
+// runRescuePhase runs the inline commands and the scripts of the phase, in
+// order, stopping at the first failure.
+func runRescuePhase(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, phase *rescuePhase) error {
+	type script struct {
+		name    string
+		content string
+	}
+
+	var scripts []script
+	if len(phase.Inline) > 0 {
+		scripts = append(scripts, script{
+			name:    "inline",
+			content: "#!/bin/sh\nset -e\n" + strings.Join(phase.Inline, "\n") + "\n",
+		})
+	}
+	for _, path := range phase.Scripts {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("could not read script: %w", err)
+		}
+		scripts = append(scripts, script{name: path, content: string(content)})
+	}
+
+	for i, script := range scripts {
+		remotePath := fmt.Sprintf("/tmp/packer-rescue-%d-%s", i, filepath.Base(script.name))
+		if err := comm.Upload(remotePath, strings.NewReader(script.content), nil); err != nil {
+			return fmt.Errorf("could not upload %s: %w", script.name, err)
+		}
+
+		ui.Say(fmt.Sprintf("Running %s in rescue system...", script.name))
+		cmd := &packersdk.RemoteCmd{
+			Command: fmt.Sprintf("chmod 0755 %[1]s && %[1]s", remotePath),
+		}
+		if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+			return err
+		}
+		if cmd.ExitStatus() != 0 {
+			return fmt.Errorf("%s exited with status %d", script.name, cmd.ExitStatus())
+		}
+	}
+	return nil
+}
+
+// enterRescue enables the rescue system and boots the server into it. A
+// powered off server is powered on, a running server is reset. A server already
+// running the rescue system is left as is.
+func enterRescue(ctx context.Context, client *hcloud.Client, serverID int64, sshKeys []*hcloud.SSHKey) error {
+	server, _, err := client.Server.GetByID(ctx, serverID)
+	if err != nil {
+		return err
+	}
+	if server == nil {
+		return fmt.Errorf("server not found: %d", serverID)
+	}
+
+	if server.RescueEnabled && server.Status == hcloud.ServerStatusRunning {
+		return nil
+	}
+	if !server.RescueEnabled {
+		if _, err := setRescue(ctx, client, server, "linux64", sshKeys); err != nil {
+			return err
+		}
+	}
+
+	var action *hcloud.Action
+	if server.Status == hcloud.ServerStatusOff {
+		action, _, err = client.Server.Poweron(ctx, server)
+	} else {
+		action, _, err = client.Server.Reset(ctx, server)
+	}
+	if err != nil {
+		return err
+	}
+	return client.Action.WaitFor(ctx, action)
+}
+
+// leaveRescue disables the rescue system if it is still enabled, and resets the
+// server into the system installed on its disk.
+func leaveRescue(ctx context.Context, client *hcloud.Client, serverID int64) error {
+	server, _, err := client.Server.GetByID(ctx, serverID)
+	if err != nil {
+		return err
+	}
+	if server == nil {
+		return fmt.Errorf("server not found: %d", serverID)
+	}
+
+	if server.RescueEnabled {
+		action, _, err := client.Server.DisableRescue(ctx, server)
+		if err != nil {
+			return err
+		}
+		if err := client.Action.WaitFor(ctx, action); err != nil {
+			return err
+		}
+	}
+
+	action, _, err := client.Server.Reset(ctx, server)
+	if err != nil {
+		return err
+	}
+	return client.Action.WaitFor(ctx, action)
+}
@@ -0,0 +1,195 @@
+package hcloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+// recordingCommunicator records the uploaded files and the started commands,
+// the commands exit with the given status.
+type recordingCommunicator struct {
+	packersdk.MockCommunicator
+
+	files      map[string]string
+	commands   []string
+	exitStatus int
+}
+
+func (c *recordingCommunicator) Upload(path string, r io.Reader, _ *os.FileInfo) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.files[path] = string(data)
+	return nil
+}
+
+func (c *recordingCommunicator) Start(_ context.Context, cmd *packersdk.RemoteCmd) error {
+	c.commands = append(c.commands, cmd.Command)
+	go cmd.SetExited(c.exitStatus)
+	return nil
+}
+
+func TestRunRescuePhase(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "zero-fill.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/bash\nzerofree /dev/sda1\n"), 0o600))
+
+	phase := &rescuePhase{
+		Inline:  []string{"sgdisk -e /dev/sda", "partprobe /dev/sda"},
+		Scripts: []string{script},
+	}
+
+	t.Run("success", func(t *testing.T) {
+		comm := &recordingCommunicator{files: map[string]string{}}
+
+		require.NoError(t, runRescuePhase(context.Background(), &packersdk.MockUi{}, comm, phase))
+		assert.Equal(t, map[string]string{
+			"/tmp/packer-rescue-0-inline":       "#!/bin/sh\nset -e\nsgdisk -e /dev/sda\npartprobe /dev/sda\n",
+			"/tmp/packer-rescue-1-zero-fill.sh": "#!/bin/bash\nzerofree /dev/sda1\n",
+		}, comm.files)
+		assert.Equal(t, []string{
+			"chmod 0755 /tmp/packer-rescue-0-inline && /tmp/packer-rescue-0-inline",
+			"chmod 0755 /tmp/packer-rescue-1-zero-fill.sh && /tmp/packer-rescue-1-zero-fill.sh",
+		}, comm.commands)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		comm := &recordingCommunicator{files: map[string]string{}, exitStatus: 1}
+
+		err := runRescuePhase(context.Background(), &packersdk.MockUi{}, comm, phase)
+		assert.EqualError(t, err, "inline exited with status 1")
+		assert.Len(t, comm.commands, 1)
+	})
+}
+
+func TestEnterRescue(t *testing.T) {
+	enableRescue := mockutil.Request{
+		Method: "POST", Path: "/servers/8/actions/enable_rescue",
+		Want: func(t *testing.T, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			assert.JSONEq(t, `{"type": "linux64", "ssh_keys": [1]}`, string(body))
+		},
+		Status: 201,
+		JSONRaw: `{
+			"root_password": "",
+			"action": { "id": 3, "status": "success" }
+		}`,
+	}
+
+	testCases := []struct {
+		name          string
+		status        string
+		rescueEnabled bool
+		wantRequests  []mockutil.Request
+	}{
+		{
+			name:   "running",
+			status: "running",
+			wantRequests: []mockutil.Request{
+				enableRescue,
+				{Method: "POST", Path: "/servers/8/actions/reset",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 4, "status": "success" }
+					}`,
+				},
+			},
+		},
+		{
+			name:   "powered off",
+			status: "off",
+			wantRequests: []mockutil.Request{
+				enableRescue,
+				{Method: "POST", Path: "/servers/8/actions/poweron",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 4, "status": "success" }
+					}`,
+				},
+			},
+		},
+		{
+			name:          "already in rescue",
+			status:        "running",
+			rescueEnabled: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := append([]mockutil.Request{
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: fmt.Sprintf(`{
+						"server": { "id": 8, "status": %q, "rescue_enabled": %t }
+					}`, tc.status, tc.rescueEnabled),
+				},
+			}, tc.wantRequests...)
+
+			server := httptest.NewServer(mockutil.Handler(t, requests))
+			defer server.Close()
+			client := hcloud.NewClient(hcloud.WithEndpoint(server.URL))
+
+			err := enterRescue(context.Background(), client, 8, []*hcloud.SSHKey{{ID: 1}})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestLeaveRescue(t *testing.T) {
+	testCases := []struct {
+		name          string
+		rescueEnabled bool
+	}{
+		{name: "rescue enabled", rescueEnabled: true},
+		{name: "rescue already disabled", rescueEnabled: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := []mockutil.Request{
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: fmt.Sprintf(`{
+						"server": { "id": 8, "rescue_enabled": %t }
+					}`, tc.rescueEnabled),
+				},
+			}
+			if tc.rescueEnabled {
+				requests = append(requests, mockutil.Request{
+					Method: "POST", Path: "/servers/8/actions/disable_rescue",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 3, "status": "success" }
+					}`,
+				})
+			}
+			requests = append(requests, mockutil.Request{
+				Method: "POST", Path: "/servers/8/actions/reset",
+				Status: 201,
+				JSONRaw: `{
+					"action": { "id": 4, "status": "success" }
+				}`,
+			})
+
+			server := httptest.NewServer(mockutil.Handler(t, requests))
+			defer server.Close()
+			client := hcloud.NewClient(hcloud.WithEndpoint(server.URL))
+
+			require.NoError(t, leaveRescue(context.Background(), client, 8))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+const (
+	// resetRootPasswordTimeout limits the time waiting for the QEMU guest
+	// agent of the server to start.
+	resetRootPasswordTimeout = 5 * time.Minute
+
+	resetRootPasswordRetryInterval = 5 * time.Second
+)
+
+// stepResetRootPassword resets the root password of the server, through the
+// QEMU guest agent, and uses it for the communicator. It allows to provision
+// images that ignore the SSH keys injected by cloud-init.
+type stepResetRootPassword struct{}
+
+func (s *stepResetRootPassword) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, client := UnpackState(state)
+
+	server := &hcloud.Server{ID: state.Get(StateServerID).(int64)}
+
+	ui.Say("Resetting root password...")
+	rootPassword, err := resetRootPassword(ctx, client, server, resetRootPasswordRetryInterval)
+	if err != nil {
+		return errorHandler(state, ui, "Could not reset root password", err)
+	}
+	useRootPassword(c, rootPassword)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepResetRootPassword) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
+
+// resetRootPassword resets the root password of the server. The action fails
+// until the QEMU guest agent is running, it is retried until the timeout.
+func resetRootPassword(ctx context.Context, client *hcloud.Client, server *hcloud.Server, interval time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, resetRootPasswordTimeout)
+	defer cancel()
+
+	for {
+		result, _, err := client.Server.ResetPassword(ctx, server)
+		if err != nil {
+			return "", err
+		}
+		err = client.Action.WaitFor(ctx, result.Action)
+		if err == nil {
+			return result.RootPassword, nil
+		}
+		if !errors.As(err, &hcloud.ActionError{}) {
+			return "", err
+		}
+		log.Printf("[DEBUG] Could not reset root password, retrying: %s", err)
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w (last error: %w)", ctx.Err(), err)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// useRootPassword uses the root password of the server for the communicator,
+// unless a password was configured.
+func useRootPassword(c *Config, rootPassword string) {
+	if rootPassword == "" {
+		return
+	}
+	packersdk.LogSecretFilter.Set(rootPassword)
+
+	switch c.Comm.Type {
+	case "ssh":
+		if c.Comm.SSHPassword == "" {
+			c.Comm.SSHPassword = rootPassword
+		}
+	case "winrm":
+		if c.Comm.WinRMPassword == "" {
+			c.Comm.WinRMPassword = rootPassword
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestStepResetRootPassword(t *testing.T) {
+	RunStepTestCases(t, []StepTestCase{
+		{
+			Name: "happy",
+			Step: &stepResetRootPassword{},
+			SetupConfigFunc: func(c *Config) {
+				c.Comm.Type = "ssh"
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/reset_password",
+					Status: 201,
+					JSONRaw: `{
+						"root_password": "dummy-password",
+						"action": { "id": 3, "status": "success" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				config := state.Get(StateConfig).(*Config)
+				assert.Equal(t, "dummy-password", config.Comm.SSHPassword)
+			},
+		},
+		{
+			Name: "keep configured password",
+			Step: &stepResetRootPassword{},
+			SetupConfigFunc: func(c *Config) {
+				c.Comm.Type = "winrm"
+				c.Comm.WinRMPassword = "configured-password"
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/reset_password",
+					Status: 201,
+					JSONRaw: `{
+						"root_password": "dummy-password",
+						"action": { "id": 3, "status": "success" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				config := state.Get(StateConfig).(*Config)
+				assert.Equal(t, "configured-password", config.Comm.WinRMPassword)
+			},
+		},
+		{
+			Name: "fail",
+			Step: &stepResetRootPassword{},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/reset_password",
+					Status: 400,
+				},
+			},
+			WantStepAction: multistep.ActionHalt,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				err, ok := state.Get(StateError).(error)
+				assert.True(t, ok)
+				assert.Regexp(t, "Could not reset root password: .*", err.Error())
+			},
+		},
+	})
+}
+
+func TestResetRootPasswordRetry(t *testing.T) {
+	server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+		{Method: "POST", Path: "/servers/8/actions/reset_password",
+			Status: 201,
+			JSONRaw: `{
+				"root_password": "first-password",
+				"action": { "id": 3, "status": "error", "error": { "code": "action_failed", "message": "guest agent not running" }}
+			}`,
+		},
+		{Method: "POST", Path: "/servers/8/actions/reset_password",
+			Status: 201,
+			JSONRaw: `{
+				"root_password": "dummy-password",
+				"action": { "id": 4, "status": "success" }
+			}`,
+		},
+	}))
+	defer server.Close()
+	client := hcloud.NewClient(hcloud.WithEndpoint(server.URL))
+
+	rootPassword, err := resetRootPassword(context.Background(), client, &hcloud.Server{ID: 8}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "dummy-password", rootPassword)
+}
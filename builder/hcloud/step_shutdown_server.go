@@ -5,28 +5,75 @@ package hcloud
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
 
 	"github.com/hetznercloud/hcloud-go/v2/hcloud"
 )
 
+// sysprepCommand generalizes a Windows system and powers it off, it is run as
+// shutdown command with the sysprep option, unless a shutdown_command is set.
+const sysprepCommand = `C:\Windows\System32\Sysprep\sysprep.exe /generalize /oobe /shutdown /quiet`
+
 type stepShutdownServer struct{}
 
-//nolint:gosimple,goimports
 func (s *stepShutdownServer) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
-	_, ui, client := UnpackState(state)
+	c, ui, client := UnpackState(state)
 
 	serverID := state.Get(StateServerID).(int64)
 
-	ui.Say("Shutting down server...")
+	comm, _ := state.Get("communicator").(packersdk.Communicator)
+	if c.Sysprep {
+		if comm == nil {
+			return errorHandler(state, ui, "", errors.New("sysprep requires a communicator"))
+		}
 
-	action, _, err := client.Server.Shutdown(ctx, &hcloud.Server{ID: serverID})
-	if err != nil {
-		return errorHandler(state, ui, "Error stopping server", err)
+		command := sysprepCommand
+		if c.ShutdownCommand != "" {
+			command = c.ShutdownCommand
+		}
+
+		ui.Say("Running sysprep...")
+		cmd := &packersdk.RemoteCmd{Command: command}
+		if err := comm.Start(ctx, cmd); err != nil {
+			return errorHandler(state, ui, "Could not run sysprep", err)
+		}
+
+		// Forcing the power off would leave a partially generalized system
+		ui.Say("Waiting for server to power off...")
+		if err := pollPowerOff(ctx, client, serverID, c.ShutdownTimeout, c.PollInterval); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				err = fmt.Errorf("server did not power off within %s", c.ShutdownTimeout)
+			}
+			return errorHandler(state, ui, "Could not run sysprep", err)
+		}
+		return multistep.ActionContinue
 	}
 
-	if err := client.Action.WaitFor(ctx, action); err != nil {
+	if c.ShutdownCommand != "" && comm != nil {
+		ui.Say("Gracefully halting server...")
+		cmd := &packersdk.RemoteCmd{Command: c.ShutdownCommand}
+		if err := comm.Start(ctx, cmd); err != nil {
+			return errorHandler(state, ui, "Could not send shutdown command", err)
+		}
+	} else {
+		ui.Say("Shutting down server...")
+		action, _, err := client.Server.Shutdown(ctx, &hcloud.Server{ID: serverID})
+		if err != nil {
+			return errorHandler(state, ui, "Error stopping server", err)
+		}
+		if err := client.Action.WaitFor(ctx, action); err != nil {
+			return errorHandler(state, ui, "Error stopping server", err)
+		}
+	}
+
+	// The shutdown only signals the guest, wait for it to actually power off
+	if err := WaitForPowerOff(ctx, ui, client, serverID, c.ShutdownTimeout, c.PollInterval); err != nil {
 		return errorHandler(state, ui, "Error stopping server", err)
 	}
 
@@ -36,3 +83,51 @@ func (s *stepShutdownServer) Run(ctx context.Context, state multistep.StateBag)
 func (s *stepShutdownServer) Cleanup(state multistep.StateBag) {
 	// no cleanup
 }
+
+// WaitForPowerOff waits for the server to power off once it was asked to shut
+// down, and forces the power off if it is still running after the timeout. It
+// is also used by the hcloud-snapshot provisioner.
+func WaitForPowerOff(ctx context.Context, ui packersdk.Ui, client *hcloud.Client, serverID int64, timeout, interval time.Duration) error {
+	ui.Say("Waiting for server to power off...")
+	err := pollPowerOff(ctx, client, serverID, timeout, interval)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+		return err
+	}
+
+	log.Printf("[WARN] Server did not power off within %s", timeout)
+	ui.Say(fmt.Sprintf("Server did not power off within %s, forcing power off...", timeout))
+	action, _, err := client.Server.Poweroff(ctx, &hcloud.Server{ID: serverID})
+	if err != nil {
+		return err
+	}
+	return client.Action.WaitFor(ctx, action)
+}
+
+// pollPowerOff polls the status of the server until it is off. The returned
+// error wraps [context.DeadlineExceeded] when the timeout expires.
+func pollPowerOff(ctx context.Context, client *hcloud.Client, serverID int64, timeout, interval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		server, _, err := client.Server.GetByID(ctx, serverID)
+		if err != nil {
+			return err
+		}
+		if server == nil {
+			return fmt.Errorf("server not found: %d", serverID)
+		}
+		if server.Status == hcloud.ServerStatusOff {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestStepShutdownServer(t *testing.T) {
+	RunStepTestCases(t, []StepTestCase{
+		{
+			Name: "happy",
+			Step: &stepShutdownServer{},
+			SetupConfigFunc: func(c *Config) {
+				c.ShutdownTimeout = time.Minute
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/shutdown",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 3, "status": "running" }
+					}`,
+				},
+				{Method: "GET", Path: "/actions?id=3&page=1&sort=status&sort=id",
+					Status: 200,
+					JSONRaw: `{
+						"actions": [
+							{ "id": 3, "status": "success" }
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: `{
+						"server": { "id": 8, "status": "running" }
+					}`,
+				},
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: `{
+						"server": { "id": 8, "status": "off" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+		},
+		{
+			Name: "happy with shutdown command",
+			Step: &stepShutdownServer{},
+			SetupConfigFunc: func(c *Config) {
+				c.ShutdownCommand = "sudo shutdown -P now"
+				c.ShutdownTimeout = time.Minute
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put("communicator", &packersdk.MockCommunicator{})
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: `{
+						"server": { "id": 8, "status": "off" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				comm := state.Get("communicator").(*packersdk.MockCommunicator)
+				assert.Equal(t, "sudo shutdown -P now", comm.StartCmd.Command)
+			},
+		},
+		{
+			Name: "timeout falls back to power off",
+			Step: &stepShutdownServer{},
+			SetupConfigFunc: func(c *Config) {
+				c.ShutdownTimeout = time.Nanosecond
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/shutdown",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 3, "status": "success" }
+					}`,
+				},
+				{Method: "POST", Path: "/servers/8/actions/poweroff",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 4, "status": "success" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+		},
+		{
+			Name: "happy with sysprep",
+			Step: &stepShutdownServer{},
+			SetupConfigFunc: func(c *Config) {
+				c.Sysprep = true
+				c.ShutdownTimeout = time.Minute
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put("communicator", &packersdk.MockCommunicator{})
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: `{
+						"server": { "id": 8, "status": "off" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				comm := state.Get("communicator").(*packersdk.MockCommunicator)
+				assert.Equal(t, sysprepCommand, comm.StartCmd.Command)
+			},
+		},
+		{
+			Name: "happy with sysprep and shutdown_command",
+			Step: &stepShutdownServer{},
+			SetupConfigFunc: func(c *Config) {
+				c.Sysprep = true
+				c.ShutdownCommand = `C:\Windows\System32\Sysprep\sysprep.exe /generalize /oobe /shutdown /quiet /unattend:C:\unattend.xml`
+				c.ShutdownTimeout = time.Minute
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put("communicator", &packersdk.MockCommunicator{})
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/servers/8",
+					Status: 200,
+					JSONRaw: `{
+						"server": { "id": 8, "status": "off" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				comm := state.Get("communicator").(*packersdk.MockCommunicator)
+				assert.Equal(t, `C:\Windows\System32\Sysprep\sysprep.exe /generalize /oobe /shutdown /quiet /unattend:C:\unattend.xml`, comm.StartCmd.Command)
+			},
+		},
+		{
+			Name: "sysprep timeout does not force power off",
+			Step: &stepShutdownServer{},
+			SetupConfigFunc: func(c *Config) {
+				c.Sysprep = true
+				c.ShutdownTimeout = time.Nanosecond
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put("communicator", &packersdk.MockCommunicator{})
+			},
+			WantStepAction: multistep.ActionHalt,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				err, ok := state.Get(StateError).(error)
+				assert.True(t, ok)
+				assert.Regexp(t, "Could not run sysprep: server did not power off within 1ns", err.Error())
+			},
+		},
+		{
+			Name: "fail shutdown",
+			Step: &stepShutdownServer{},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/8/actions/shutdown",
+					Status: 400,
+				},
+			},
+			WantStepAction: multistep.ActionHalt,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				err, ok := state.Get(StateError).(error)
+				assert.True(t, ok)
+				assert.Regexp(t, "Error stopping server: .*", err.Error())
+			},
+		},
+	})
+}
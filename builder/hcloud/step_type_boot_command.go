@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+type bootCommandTemplateData struct {
+	Name     string
+	ServerIP string
+}
+
+// stepTypeBootCommand types the boot command over the VNC console of the
+// server, for example to start an OS installer booted from an ISO.
+type stepTypeBootCommand struct{}
+
+func (s *stepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, client := UnpackState(state)
+
+	serverID := state.Get(StateServerID).(int64)
+
+	if c.BootWait > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", c.BootWait))
+		select {
+		case <-time.After(c.BootWait):
+		case <-ctx.Done():
+			return multistep.ActionHalt
+		}
+	}
+
+	ui.Say("Connecting to server console...")
+	vnc, err := requestVNC(ctx, client, serverID)
+	if err != nil {
+		return errorHandler(state, ui, "Could not type boot command", err)
+	}
+	defer vnc.Close()
+
+	c.ctx.Data = &bootCommandTemplateData{
+		Name:     c.ServerName,
+		ServerIP: state.Get(StateServerIP).(string),
+	}
+	command, err := interpolate.Render(c.FlatBootCommand(), &c.ctx)
+	if err != nil {
+		return errorHandler(state, ui, "Could not render boot command", err)
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence(command)
+	if err != nil {
+		return errorHandler(state, ui, "Could not parse boot command", err)
+	}
+
+	ui.Say("Typing the boot command over VNC...")
+	driver := bootcommand.NewVNCDriver(vnc, c.BootKeyInterval)
+	if err := seq.Do(ctx, driver); err != nil {
+		return errorHandler(state, ui, "Could not type boot command", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepTypeBootCommand) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestStepTypeBootCommand(t *testing.T) {
+	vncServer := newVNCTestServer(t, "secret")
+
+	RunStepTestCases(t, []StepTestCase{
+		{
+			Name: "happy",
+			Step: &stepTypeBootCommand{},
+			SetupConfigFunc: func(c *Config) {
+				c.BootCommand = []string{"a<enter>"}
+				c.BootKeyInterval = time.Millisecond
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put(StateServerIP, "1.2.3.4")
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/request_console",
+					Status: 201,
+					JSONRaw: fmt.Sprintf(`{
+						"wss_url": %q,
+						"password": "secret",
+						"action": { "id": 3, "status": "success" }
+					}`, vncServer.URL()),
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				assert.Equal(t, []vncTestKeyEvent{
+					{Key: 'a', Down: true},
+					{Key: 'a', Down: false},
+					{Key: 0xFF0D, Down: true},
+					{Key: 0xFF0D, Down: false},
+				}, vncServer.KeyEvents())
+			},
+		},
+		{
+			Name: "fail request console",
+			Step: &stepTypeBootCommand{},
+			SetupConfigFunc: func(c *Config) {
+				c.BootCommand = []string{"a<enter>"}
+			},
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+				state.Put(StateServerIP, "1.2.3.4")
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/request_console",
+					Status: 422,
+					JSONRaw: `{
+						"error": { "code": "invalid_input", "message": "invalid input" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionHalt,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				err, ok := state.Get(StateError).(error)
+				assert.True(t, ok)
+				assert.Regexp(t, "Could not type boot command: could not request server console: .*", err.Error())
+			},
+		},
+	})
+}
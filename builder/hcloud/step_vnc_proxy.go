@@ -0,0 +1,186 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// stepVNCProxy starts a local VNC server, forwarding the connections to the
+// console of the server. It allows to watch and interact with the server using
+// a standard VNC viewer while debugging a build.
+type stepVNCProxy struct {
+	listener net.Listener
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	console hcloud.ServerRequestConsoleResult
+}
+
+func (s *stepVNCProxy) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	_, ui, client := UnpackState(state)
+
+	serverID := state.Get(StateServerID).(int64)
+
+	// The proxy is only a debugging aid, errors must not stop the build
+	ui.Say("Starting VNC proxy...")
+	if err := s.requestConsole(ctx, client, serverID); err != nil {
+		ui.Error(fmt.Sprintf("Could not start VNC proxy: could not request server console: %s", err))
+		return multistep.ActionContinue
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		ui.Error(fmt.Sprintf("Could not start VNC proxy: %s", err))
+		return multistep.ActionContinue
+	}
+	s.listener = listener
+	s.conns = make(map[net.Conn]struct{})
+
+	// The connections outlive the step, they are stopped in the cleanup
+	serveCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.serve(serveCtx, ui, client, serverID)
+	}()
+
+	ui.Say(fmt.Sprintf("VNC proxy listening on: vnc://%s", listener.Addr()))
+	ui.Say(fmt.Sprintf("VNC console password: %s", s.console.Password))
+
+	return multistep.ActionContinue
+}
+
+func (s *stepVNCProxy) Cleanup(state multistep.StateBag) {
+	if s.listener == nil {
+		return
+	}
+
+	s.cancel()
+	s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *stepVNCProxy) requestConsole(ctx context.Context, client *hcloud.Client, serverID int64) error {
+	result, _, err := client.Server.RequestConsole(ctx, &hcloud.Server{ID: serverID})
+	if err != nil {
+		return err
+	}
+	if err := client.Action.WaitFor(ctx, result.Action); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.console = result
+	s.mu.Unlock()
+	return nil
+}
+
+// serve accepts the local connections until the listener is closed.
+func (s *stepVNCProxy) serve(ctx context.Context, ui packersdk.Ui, client *hcloud.Client, serverID int64) {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[ERROR] VNC proxy stopped: %s", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+
+			if err := s.forward(ctx, ui, client, serverID, conn); err != nil {
+				ui.Error(fmt.Sprintf("VNC proxy: %s", err))
+			}
+		}()
+	}
+}
+
+// forward copies the data between the local connection and the console
+// websocket, the RFB handshake is performed by the VNC viewer.
+func (s *stepVNCProxy) forward(ctx context.Context, ui packersdk.Ui, client *hcloud.Client, serverID int64, conn net.Conn) error {
+	s.mu.Lock()
+	console := s.console
+	s.mu.Unlock()
+
+	remote, err := dialConsole(ctx, console.WSSURL)
+	if err != nil {
+		// The proxy was stopped
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// The console might have expired, request a new one
+		log.Printf("[WARN] Could not connect to server console: %s", err)
+		if err := s.requestConsole(ctx, client, serverID); err != nil {
+			return fmt.Errorf("could not request server console: %w", err)
+		}
+
+		s.mu.Lock()
+		console = s.console
+		s.mu.Unlock()
+
+		ui.Say(fmt.Sprintf("VNC console password changed: %s", console.Password))
+		remote, err = dialConsole(ctx, console.WSSURL)
+		if err != nil {
+			return fmt.Errorf("could not connect to server console: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.conns[remote] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, remote)
+		s.mu.Unlock()
+		remote.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		io.Copy(remote, conn) //nolint:errcheck
+		remote.Close()
+	}()
+	io.Copy(conn, remote) //nolint:errcheck
+	conn.Close()
+	<-done
+
+	return nil
+}
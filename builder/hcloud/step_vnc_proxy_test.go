@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestStepVNCProxy(t *testing.T) {
+	vncServer := newVNCTestServer(t, "secret")
+
+	step := &stepVNCProxy{}
+	failingStep := &stepVNCProxy{}
+
+	RunStepTestCases(t, []StepTestCase{
+		{
+			Name: "happy",
+			Step: step,
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/request_console",
+					Status: 201,
+					JSONRaw: fmt.Sprintf(`{
+						"wss_url": %q,
+						"password": "secret",
+						"action": { "id": 3, "status": "success" }
+					}`, vncServer.URL()),
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				require.NotNil(t, step.listener)
+
+				// The VNC viewer performs the handshake through the proxy
+				conn, err := net.Dial("tcp", step.listener.Addr().String())
+				require.NoError(t, err)
+
+				viewer, err := newVNCClient(conn, "secret")
+				require.NoError(t, err)
+				assert.Equal(t, "dummy-server", viewer.Name)
+				require.NoError(t, viewer.KeyEvent(0xFF0D, true))
+				require.NoError(t, viewer.Close())
+
+				assert.Equal(t, []vncTestKeyEvent{
+					{Key: 0xFF0D, Down: true},
+				}, vncServer.KeyEvents())
+
+				step.Cleanup(state)
+			},
+		},
+		{
+			Name: "fail request console",
+			Step: failingStep,
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/request_console",
+					Status: 422,
+					JSONRaw: `{
+						"error": { "code": "invalid_input", "message": "invalid input" }
+					}`,
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				_, ok := state.GetOk(StateError)
+				assert.False(t, ok)
+				assert.Nil(t, failingStep.listener)
+			},
+		},
+	})
+}
+
+func TestStepVNCProxyCleanupPendingConsole(t *testing.T) {
+	// The console accepts the connections, but never completes the handshake
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer console.Close()
+	go func() {
+		for {
+			conn, err := console.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	step := &stepVNCProxy{}
+
+	RunStepTestCases(t, []StepTestCase{
+		{
+			Name: "happy",
+			Step: step,
+			SetupStateFunc: func(state multistep.StateBag) {
+				state.Put(StateServerID, int64(8))
+			},
+			WantRequests: []mockutil.Request{
+				{
+					Method: "POST", Path: "/servers/8/actions/request_console",
+					Status: 201,
+					JSONRaw: fmt.Sprintf(`{
+						"wss_url": "ws://%s/",
+						"password": "secret",
+						"action": { "id": 3, "status": "success" }
+					}`, console.Addr()),
+				},
+			},
+			WantStepAction: multistep.ActionContinue,
+			WantStateFunc: func(t *testing.T, state multistep.StateBag) {
+				require.NotNil(t, step.listener)
+
+				conn, err := net.Dial("tcp", step.listener.Addr().String())
+				require.NoError(t, err)
+				defer conn.Close()
+
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					step.Cleanup(state)
+				}()
+
+				select {
+				case <-done:
+				case <-time.After(5 * time.Second):
+					t.Fatal("cleanup did not stop the pending console connection")
+				}
+			},
+		},
+	})
+}
@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	cloudInitStatusCommand       = "cloud-init status --wait --format=json"
+	cloudInitLegacyStatusCommand = "cloud-init status --wait"
+	cloudInitLogsCommand         = "tail -n +1 /var/log/cloud-init*.log"
+)
+
+// cloudInitModuleRegexp matches the module name in the errors reported by
+// cloud-init, e.g. "('scripts_user', RuntimeError(...))".
+var cloudInitModuleRegexp = regexp.MustCompile(`^\('([^']+)'`)
+
+// cloudInitStatus is the output of "cloud-init status --format=json".
+type cloudInitStatus struct {
+	Status            string              `json:"status"`
+	ExtendedStatus    string              `json:"extended_status"`
+	Errors            []string            `json:"errors"`
+	RecoverableErrors map[string][]string `json:"recoverable_errors"`
+}
+
+// stepWaitForCloudInit waits for cloud-init to finish on the server. When
+// cloud-init failed, its logs are written to a local file and the build halts.
+type stepWaitForCloudInit struct{}
+
+func (s *stepWaitForCloudInit) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, _ := UnpackState(state)
+
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	ui.Say("Waiting for cloud-init to finish...")
+	stdout := new(bytes.Buffer)
+	cmd := &packersdk.RemoteCmd{Command: cloudInitStatusCommand, Stdout: stdout}
+	exitStatus, err := runQuiet(ctx, comm, cmd)
+	if err != nil {
+		return errorHandler(state, ui, "Could not get cloud-init status", err)
+	}
+
+	var status cloudInitStatus
+	if err := json.Unmarshal(stdout.Bytes(), &status); err != nil {
+		// Older cloud-init versions reject the --format flag before waiting,
+		// only the exit status of the plain command is available: 0 for
+		// success, 2 for recoverable errors.
+		cmd := &packersdk.RemoteCmd{Command: cloudInitLegacyStatusCommand, Stdout: io.Discard}
+		exitStatus, err = runQuiet(ctx, comm, cmd)
+		if err != nil {
+			return errorHandler(state, ui, "Could not get cloud-init status", err)
+		}
+
+		switch exitStatus {
+		case 0:
+			ui.Say("cloud-init finished")
+			return multistep.ActionContinue
+		case 2:
+			ui.Error("cloud-init finished with recoverable errors")
+			return multistep.ActionContinue
+		}
+		status = cloudInitStatus{
+			Status: "error",
+			Errors: []string{fmt.Sprintf("cloud-init status exited with status %d", exitStatus)},
+		}
+	}
+
+	if status.Status != "error" && len(status.Errors) == 0 {
+		if strings.HasPrefix(status.ExtendedStatus, "degraded") || len(status.RecoverableErrors) > 0 {
+			ui.Error(fmt.Sprintf("cloud-init finished with recoverable errors: %s", formatRecoverableErrors(status.RecoverableErrors)))
+			return multistep.ActionContinue
+		}
+		ui.Say(fmt.Sprintf("cloud-init finished with status: %s", status.Status))
+		return multistep.ActionContinue
+	}
+
+	logFile := fmt.Sprintf("cloud-init_%s.log", c.ServerName)
+	ui.Say(fmt.Sprintf("Collecting cloud-init logs to %s...", logFile))
+	if err := collectCloudInitLogs(ctx, comm, c, logFile); err != nil {
+		ui.Error(fmt.Sprintf("Could not collect cloud-init logs: %s", err))
+	}
+
+	if len(status.Errors) == 0 {
+		status.Errors = []string{fmt.Sprintf("status %s", status.Status)}
+	}
+	return errorHandler(state, ui, cloudInitFailure(status.Errors), errors.New(strings.Join(status.Errors, "; ")))
+}
+
+func (s *stepWaitForCloudInit) Cleanup(state multistep.StateBag) {}
+
+// cloudInitFailure describes the failure, naming the module of the first error
+// reported by cloud-init.
+func cloudInitFailure(errs []string) string {
+	for _, e := range errs {
+		if match := cloudInitModuleRegexp.FindStringSubmatch(e); match != nil {
+			return fmt.Sprintf("cloud-init failed in module %s", match[1])
+		}
+	}
+	return "cloud-init failed"
+}
+
+// formatRecoverableErrors returns the recoverable errors sorted by level.
+func formatRecoverableErrors(errs map[string][]string) string {
+	var messages []string
+	for _, level := range slices.Sorted(maps.Keys(errs)) {
+		for _, e := range errs[level] {
+			messages = append(messages, fmt.Sprintf("%s: %s", level, e))
+		}
+	}
+	if len(messages) == 0 {
+		return "none reported"
+	}
+	return strings.Join(messages, "; ")
+}
+
+// collectCloudInitLogs writes the cloud-init logs of the server to the local file.
+func collectCloudInitLogs(ctx context.Context, comm packersdk.Communicator, c *Config, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stderr := new(bytes.Buffer)
+	cmd := &packersdk.RemoteCmd{
+		Command: privilegedCommand(c, cloudInitLogsCommand),
+		Stdout:  f,
+		Stderr:  stderr,
+	}
+	exitStatus, err := runQuiet(ctx, comm, cmd)
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("command exited with status %d: %s", exitStatus, strings.TrimSpace(stderr.String()))
+	}
+	return f.Close()
+}
+
+// runQuiet runs the command without printing its output, and returns its exit
+// status.
+func runQuiet(ctx context.Context, comm packersdk.Communicator, cmd *packersdk.RemoteCmd) (int, error) {
+	if err := comm.Start(ctx, cmd); err != nil {
+		return 0, err
+	}
+
+	exited := make(chan int, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case exitStatus := <-exited:
+		return exitStatus, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
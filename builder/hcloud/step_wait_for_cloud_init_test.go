@@ -0,0 +1,152 @@
+package hcloud
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// cloudInitCommunicator answers the cloud-init status and logs commands.
+type cloudInitCommunicator struct {
+	packersdk.MockCommunicator
+
+	status           string
+	exitStatus       int
+	legacyExitStatus int
+	commands         []string
+}
+
+func (c *cloudInitCommunicator) Start(_ context.Context, cmd *packersdk.RemoteCmd) error {
+	c.commands = append(c.commands, cmd.Command)
+	switch cmd.Command {
+	case cloudInitStatusCommand:
+		io.WriteString(cmd.Stdout, c.status)
+		go cmd.SetExited(c.exitStatus)
+		return nil
+	case cloudInitLegacyStatusCommand:
+		io.WriteString(cmd.Stdout, "status: done\n")
+		go cmd.SetExited(c.legacyExitStatus)
+		return nil
+	}
+	io.WriteString(cmd.Stdout, "==> /var/log/cloud-init.log <==\nfailed\n")
+	go cmd.SetExited(0)
+	return nil
+}
+
+func TestStepWaitForCloudInit(t *testing.T) {
+	testCases := []struct {
+		name             string
+		username         string
+		status           string
+		exitStatus       int
+		legacyExitStatus int
+		wantAction       multistep.StepAction
+		wantError        string
+		wantCommands     []string
+		wantLogs         bool
+	}{
+		{
+			name:         "done",
+			username:     "root",
+			status:       `{"status": "done", "extended_status": "done", "errors": [], "recoverable_errors": {}}`,
+			wantAction:   multistep.ActionContinue,
+			wantCommands: []string{cloudInitStatusCommand},
+		},
+		{
+			name:         "degraded",
+			username:     "root",
+			status:       `{"status": "done", "extended_status": "degraded done", "errors": [], "recoverable_errors": {"WARNING": ["Failed to set locale"]}}`,
+			exitStatus:   2,
+			wantAction:   multistep.ActionContinue,
+			wantCommands: []string{cloudInitStatusCommand},
+		},
+		{
+			name:         "legacy done",
+			username:     "root",
+			status:       "",
+			exitStatus:   2,
+			wantAction:   multistep.ActionContinue,
+			wantCommands: []string{cloudInitStatusCommand, cloudInitLegacyStatusCommand},
+		},
+		{
+			name:             "legacy degraded",
+			username:         "root",
+			status:           "",
+			exitStatus:       2,
+			legacyExitStatus: 2,
+			wantAction:       multistep.ActionContinue,
+			wantCommands:     []string{cloudInitStatusCommand, cloudInitLegacyStatusCommand},
+		},
+		{
+			name:       "error",
+			username:   "root",
+			status:     `{"status": "error", "extended_status": "error - done", "errors": ["('scripts_user', RuntimeError('Runparts: 1 failures (part-001) in 1 attempted commands'))"]}`,
+			exitStatus: 1,
+			wantAction: multistep.ActionHalt,
+			wantError:  "cloud-init failed in module scripts_user: ('scripts_user', RuntimeError('Runparts: 1 failures (part-001) in 1 attempted commands'))",
+			wantCommands: []string{
+				cloudInitStatusCommand,
+				cloudInitLogsCommand,
+			},
+			wantLogs: true,
+		},
+		{
+			name:             "legacy error with sudo",
+			username:         "debian",
+			status:           "",
+			exitStatus:       2,
+			legacyExitStatus: 1,
+			wantAction:       multistep.ActionHalt,
+			wantError:        "cloud-init failed: cloud-init status exited with status 1",
+			wantCommands: []string{
+				cloudInitStatusCommand,
+				cloudInitLegacyStatusCommand,
+				`sudo -n sh -c 'tail -n +1 /var/log/cloud-init*.log'`,
+			},
+			wantLogs: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+
+			config := &Config{ServerName: "dummy-server", WaitForCloudInit: true}
+			config.Comm.Type = "ssh"
+			config.Comm.SSHUsername = tc.username
+
+			comm := &cloudInitCommunicator{status: tc.status, exitStatus: tc.exitStatus, legacyExitStatus: tc.legacyExitStatus}
+
+			state := NewTestState(t)
+			state.Put(StateConfig, config)
+			state.Put(StateHCloudClient, hcloud.NewClient())
+			state.Put("communicator", comm)
+
+			step := &stepWaitForCloudInit{}
+			assert.Equal(t, tc.wantAction, step.Run(t.Context(), state))
+			assert.Equal(t, tc.wantCommands, comm.commands)
+
+			if tc.wantError != "" {
+				err, ok := state.Get(StateError).(error)
+				require.True(t, ok)
+				assert.EqualError(t, err, tc.wantError)
+			}
+
+			logs, err := os.ReadFile("cloud-init_dummy-server.log")
+			if tc.wantLogs {
+				require.NoError(t, err)
+				assert.Equal(t, "==> /var/log/cloud-init.log <==\nfailed\n", string(logs))
+			} else {
+				assert.ErrorIs(t, err, os.ErrNotExist)
+			}
+		})
+	}
+}
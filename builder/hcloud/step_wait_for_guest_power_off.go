@@ -0,0 +1,39 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+)
+
+// stepWaitForGuestPowerOff waits for the system of the server to power itself
+// off, when no communicator is used. For example, once cloud-init or Ignition
+// configured the system from the user data.
+type stepWaitForGuestPowerOff struct{}
+
+func (s *stepWaitForGuestPowerOff) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, client := UnpackState(state)
+
+	serverID := state.Get(StateServerID).(int64)
+
+	ui.Say(fmt.Sprintf("Waiting up to %s for server to power itself off...", c.ShutdownTimeout))
+	err := pollPowerOff(ctx, client, serverID, c.ShutdownTimeout, c.PollInterval)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("server did not power off within %s, "+
+				"the user data must power off the server once the system is configured", c.ShutdownTimeout)
+		}
+		return errorHandler(state, ui, "Could not wait for server to power off", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepWaitForGuestPowerOff) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	writeDiskImageScriptPath = "/tmp/packer-disk-image.sh"
+
+	// rescueDisk is the disk of the server, as seen from the rescue system.
+	rescueDisk = "/dev/sda"
+)
+
+// stepWriteDiskImage downloads the disk image from the rescue system and writes
+// it to the disk of the server, before rebooting the server into it.
+type stepWriteDiskImage struct {
+	connect *communicator.StepConnect
+}
+
+func (s *stepWriteDiskImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	c, ui, client := UnpackState(state)
+
+	serverID := state.Get(StateServerID).(int64)
+
+	ui.Say("Connecting to rescue system...")
+	s.connect = rescueConnectStep(c)
+	if action := s.connect.Run(ctx, state); action != multistep.ActionContinue {
+		return action
+	}
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	if err := writeDiskImage(ctx, ui, comm, c, rescueDisk); err != nil {
+		return errorHandler(state, ui, "Could not write disk image", err)
+	}
+
+	// The rescue_before phase runs in the same rescue system, before the first
+	// boot of the disk image
+	if c.RescueBefore != nil {
+		return multistep.ActionContinue
+	}
+
+	ui.Say("Rebooting server out of rescue mode...")
+	if err := leaveRescue(ctx, client, serverID); err != nil {
+		return errorHandler(state, ui, "Could not disable rescue mode", err)
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *stepWriteDiskImage) Cleanup(state multistep.StateBag) {
+	if s.connect != nil {
+		s.connect.Cleanup(state)
+	}
+}
+
+// rescueConnectStep returns a step connecting to the rescue system of the
+// server. The rescue system only accepts the root user, authenticated with the
+// SSH keys of the server.
+func rescueConnectStep(c *Config) *communicator.StepConnect {
+	comm := c.rescueComm
+	// The temporary SSH key is stored in the communicator of the build
+	comm.SSHPrivateKey = c.Comm.SSHPrivateKey
+	comm.SSHPublicKey = c.Comm.SSHPublicKey
+
+	return &communicator.StepConnect{
+		Config:    &comm,
+		Host:      getServerIP,
+		SSHConfig: comm.SSHConfigFunc(),
+	}
+}
+
+// writeDiskImage downloads the disk image on the server and writes it to the
+// disk, the checksum of the downloaded data is verified afterwards.
+func writeDiskImage(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, c *Config, disk string) error {
+	algorithm, checksum, err := parseDiskImageChecksum(c.DiskImageChecksum)
+	if err != nil {
+		return err
+	}
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+
+	source := "curl -fsSL --retry 3 " + shellQuote(c.DiskImageURL)
+	script := WriteDiskImageScript(source, c.DiskImageFormat, algorithm, disk, 0)
+	if err := comm.Upload(writeDiskImageScriptPath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("could not upload script: %w", err)
+	}
+
+	ui.Say(fmt.Sprintf("Writing disk image %s to %s...", c.DiskImageURL, disk))
+	stdout := new(bytes.Buffer)
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("bash %s", writeDiskImageScriptPath),
+		Stdout:  stdout,
+	}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return err
+	}
+	if cmd.ExitStatus() != 0 {
+		return fmt.Errorf("script exited with status %d", cmd.ExitStatus())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	remoteChecksum := strings.TrimSpace(lines[len(lines)-1])
+	if checksum == "" {
+		ui.Say(fmt.Sprintf("Disk image checksum: %s:%s", algorithm, remoteChecksum))
+		return nil
+	}
+	if !strings.EqualFold(checksum, remoteChecksum) {
+		return fmt.Errorf("checksum mismatch: expected %s:%s, got %s:%s", algorithm, checksum, algorithm, remoteChecksum)
+	}
+	ui.Say(fmt.Sprintf("Disk image checksum verified: %s:%s", algorithm, remoteChecksum))
+
+	return nil
+}
+
+// WriteDiskImageScript returns a script writing the disk image, read from the
+// output of the source command, to the disk, and printing the checksum of the
+// read data. The format is raw, a compression format, or qcow2. qemu-img cannot
+// read a qcow2 image from a pipe, and the rescue system keeps its files in
+// memory, so the image of the given size is staged at the end of the disk and
+// converted from there.
+func WriteDiskImageScript(source, format, algorithm, disk string, size int64) string {
+	var prepare, decompress, seek, finish string
+	switch format {
+	case "gz":
+		decompress = "gzip -dc | "
+	case "xz":
+		decompress = "xz -dc | "
+	case "bz2":
+		decompress = "bzip2 -dc | "
+	case "zst":
+		decompress = "zstd -dc | "
+	case "qcow2":
+		prepare = fmt.Sprintf(`command -v qemu-img >/dev/null || { apt-get update -qq && apt-get install -y -qq qemu-utils; } </dev/null
+offset=$(( ($(blockdev --getsize64 %[1]s) - %[2]d) / 1048576 * 1048576 ))
+if (( offset <= 0 )); then
+  echo "The image does not fit on %[1]s" >&2
+  exit 1
+fi
+`, disk, size)
+		seek = `oflag=seek_bytes seek="$offset" `
+		finish = fmt.Sprintf(`image="json:{\"driver\": \"qcow2\", \"file\": {\"driver\": \"raw\", \"offset\": $offset, \"size\": %[2]d, \"file\": {\"driver\": \"host_device\", \"filename\": \"%[1]s\", \"locking\": \"off\"}}}"
+virtual_size=$(qemu-img info --output=json "$image" | sed -n 's/^ *"virtual-size": *\([0-9]*\).*/\1/p')
+if (( virtual_size > offset )); then
+  echo "The image is too large to be converted on %[1]s" >&2
+  exit 1
+fi
+qemu-img convert -n -O raw "$image" %[1]s
+blkdiscard -z -o "$offset" %[1]s
+`, disk, size)
+	}
+	return fmt.Sprintf(`set -euo pipefail
+%stmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+mkfifo "$tmp/fifo"
+%ssum <"$tmp/fifo" >"$tmp/checksum" &
+%s | tee "$tmp/fifo" | %sdd of=%s bs=4M %sconv=fsync status=none
+wait
+%ssync
+cut -d ' ' -f 1 "$tmp/checksum"
+`, prepare, algorithm, source, decompress, disk, seek, finish)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,129 @@
+package hcloud
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/packer-plugin-hcloud/internal/testutil"
+)
+
+func TestWriteDiskImage(t *testing.T) {
+	for _, name := range []string{"bash", "curl", "gzip"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is required", name)
+		}
+	}
+
+	content := bytes.Repeat([]byte("packer"), 4096)
+
+	compressed := new(bytes.Buffer)
+	writer := gzip.NewWriter(compressed)
+	_, err := writer.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	sum := sha256.Sum256(compressed.Bytes())
+	checksum := "sha256:" + hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/disk.raw.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(compressed.Bytes()) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		url      string
+		checksum string
+		wantErr  string
+	}{
+		{
+			name:     "verified",
+			url:      server.URL + "/disk.raw.gz",
+			checksum: checksum,
+		},
+		{
+			name:     "no verification",
+			url:      server.URL + "/disk.raw.gz",
+			checksum: "none",
+		},
+		{
+			name:     "checksum mismatch",
+			url:      server.URL + "/disk.raw.gz",
+			checksum: "sha256:" + strings.Repeat("0", 64),
+			wantErr:  "checksum mismatch",
+		},
+		{
+			name:     "not found",
+			url:      server.URL + "/missing.raw.gz",
+			checksum: "none",
+			wantErr:  "script exited with status",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			disk := filepath.Join(t.TempDir(), "sda")
+			require.NoError(t, os.WriteFile(disk, nil, 0o600))
+
+			config := &Config{
+				DiskImageURL:      tc.url,
+				DiskImageChecksum: tc.checksum,
+				DiskImageFormat:   "gz",
+			}
+			comm := testutil.NewLocalCommunicator("")
+
+			err := writeDiskImage(context.Background(), &packersdk.MockUi{}, comm, config, disk)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			written, err := os.ReadFile(disk)
+			require.NoError(t, err)
+			assert.Equal(t, content, written)
+		})
+	}
+}
+
+func TestDiskImageFormatFromURL(t *testing.T) {
+	assert.Equal(t, "xz", diskImageFormatFromURL("https://example.com/metal-amd64.raw.xz"))
+	assert.Equal(t, "bz2", diskImageFormatFromURL("https://example.com/flatcar_production_hetzner_image.bin.bz2"))
+	assert.Equal(t, "zst", diskImageFormatFromURL("https://example.com/disk.img.zst?token=abc"))
+	assert.Equal(t, "raw", diskImageFormatFromURL("https://example.com/disk.img"))
+}
+
+func TestParseDiskImageChecksum(t *testing.T) {
+	algorithm, value, err := parseDiskImageChecksum("sha512:" + strings.Repeat("ab", 64))
+	require.NoError(t, err)
+	assert.Equal(t, "sha512", algorithm)
+	assert.Equal(t, strings.Repeat("ab", 64), value)
+
+	algorithm, _, err = parseDiskImageChecksum("none")
+	require.NoError(t, err)
+	assert.Empty(t, algorithm)
+
+	_, _, err = parseDiskImageChecksum("md5:d41d8cd98f00b204e9800998ecf8427e")
+	assert.ErrorContains(t, err, "disk_image_checksum must be in the")
+
+	_, _, err = parseDiskImageChecksum("sha256:abcd")
+	assert.ErrorContains(t, err, "not a valid sha256 checksum")
+}
@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+)
+
+// userDataMaxSize is the maximum size of the user data accepted by the API.
+const userDataMaxSize = 32 * 1024
+
+// userDataPartTypes maps the types of the user data parts to the MIME types
+// understood by cloud-init.
+var userDataPartTypes = map[string]string{
+	"cloud-config": "text/cloud-config",
+	"shell-script": "text/x-shellscript",
+	"include":      "text/x-include-url",
+}
+
+type userDataTemplateData struct {
+	ServerName string
+	BuildName  string
+	Location   string
+}
+
+// renderUserData assembles the user data into a MIME multipart document, from
+// the rendered user data parts or the plain user data, and the cloud-config
+// injecting the SSH host key. Documents exceeding the size limit of the API are
+// compressed with gzip and base64 encoded, the API does not accept binary user
+// data, cloud-init decodes it.
+func renderUserData(c *Config, location, userData string, hostKey *sshHostKey) (string, error) {
+	if isMultipartUserData(userData) {
+		return "", errors.New("user data is already a MIME multipart document, use user_data_parts instead")
+	}
+
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%q\r\n\r\n", writer.Boundary())
+
+	c.ctx.Data = &userDataTemplateData{
+		ServerName: c.ServerName,
+		BuildName:  c.PackerBuildName,
+		Location:   location,
+	}
+
+	count := 0
+	writePart := func(contentType, content string) error {
+		count++
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", fmt.Sprintf("%s; charset=\"utf-8\"", contentType))
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"part-%03d\"", count))
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(content))
+		return err
+	}
+
+	for i, part := range c.UserDataParts {
+		content := part.Content
+		if part.File != "" {
+			data, err := os.ReadFile(part.File)
+			if err != nil {
+				return "", fmt.Errorf("user_data_parts[%d]: could not read file: %w", i, err)
+			}
+			content = string(data)
+		}
+
+		content, err := interpolate.Render(content, &c.ctx)
+		if err != nil {
+			return "", fmt.Errorf("user_data_parts[%d]: could not render template: %w", i, err)
+		}
+
+		if err := writePart(userDataPartTypes[part.Type], content); err != nil {
+			return "", err
+		}
+	}
+	// cloud-init detects the type of plain text parts from their content
+	if userData != "" {
+		if err := writePart("text/plain", userData); err != nil {
+			return "", err
+		}
+	}
+	if hostKey != nil {
+		if err := writePart("text/cloud-config", hostKey.cloudConfig()); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	if buf.Len() <= userDataMaxSize {
+		return buf.String(), nil
+	}
+
+	compressed := new(bytes.Buffer)
+	gz, err := gzip.NewWriterLevel(compressed, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := gz.Write(buf.Bytes()); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(compressed.Bytes())
+	if len(encoded) > userDataMaxSize {
+		return "", fmt.Errorf("user data is %d bytes once compressed and encoded, exceeding the limit of %d bytes",
+			len(encoded), userDataMaxSize)
+	}
+	return encoded, nil
+}
+
+// isMultipartUserData reports whether the user data is a MIME multipart
+// document, which cannot be nested in another document.
+func isMultipartUserData(userData string) bool {
+	header := strings.ToLower(userData)
+	return strings.HasPrefix(header, "mime-version:") || strings.HasPrefix(header, "content-type: multipart/")
+}
@@ -0,0 +1,218 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseUserData returns the content type and the content of each part of the
+// user data document.
+func parseUserData(t *testing.T, userData string) [][2]string {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	require.NoError(t, err)
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/mixed", mediaType)
+
+	var parts [][2]string
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		content, err := io.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, [2]string{part.Header.Get("Content-Type"), string(content)})
+	}
+	return parts
+}
+
+func TestRenderUserDataParts(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "setup.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho {{ .BuildName }}\n"), 0o600))
+
+	config := &Config{
+		ServerName: "dummy-server",
+		UserDataParts: []userDataPart{
+			{Type: "cloud-config", Content: "#cloud-config\nhostname: {{ .ServerName }}-{{ .Location }}\n"},
+			{Type: "shell-script", File: script},
+			{Type: "include", Content: "https://example.com/user-data"},
+		},
+	}
+	config.PackerBuildName = "dummy-build"
+
+	userData, err := renderUserData(config, "fsn1", "", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][2]string{
+		{`text/cloud-config; charset="utf-8"`, "#cloud-config\nhostname: dummy-server-fsn1\n"},
+		{`text/x-shellscript; charset="utf-8"`, "#!/bin/sh\necho dummy-build\n"},
+		{`text/x-include-url; charset="utf-8"`, "https://example.com/user-data"},
+	}, parseUserData(t, userData))
+}
+
+func TestRenderUserDataPartsCompressed(t *testing.T) {
+	// Large but compressible content
+	content := "#cloud-config\nwrite_files:\n" + strings.Repeat("  - path: /etc/motd\n    content: hello\n", 2000)
+
+	config := &Config{
+		UserDataParts: []userDataPart{{Type: "cloud-config", Content: content}},
+	}
+
+	userData, err := renderUserData(config, "fsn1", "", nil)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(userData), userDataMaxSize)
+
+	compressed, err := base64.StdEncoding.DecodeString(userData)
+	require.NoError(t, err)
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	decompressed, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	parts := parseUserData(t, string(decompressed))
+	require.Len(t, parts, 1)
+	assert.Equal(t, content, parts[0][1])
+}
+
+func TestRenderUserDataPartsTooLarge(t *testing.T) {
+	// Random content cannot be compressed
+	random := make([]byte, userDataMaxSize)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+
+	config := &Config{
+		UserDataParts: []userDataPart{{Type: "shell-script", Content: "#!/bin/sh\n# " + hex.EncodeToString(random)}},
+	}
+
+	_, err = renderUserData(config, "fsn1", "", nil)
+	assert.ErrorContains(t, err, "exceeding the limit of 32768 bytes")
+}
+
+func TestRenderUserDataWithHostKey(t *testing.T) {
+	hostKey, err := generateSSHHostKey()
+	require.NoError(t, err)
+
+	t.Run("plain user data", func(t *testing.T) {
+		userData, err := renderUserData(&Config{}, "fsn1", "#cloud-config\npackages: [nginx]\n", hostKey)
+		require.NoError(t, err)
+
+		assert.Equal(t, [][2]string{
+			{`text/plain; charset="utf-8"`, "#cloud-config\npackages: [nginx]\n"},
+			{`text/cloud-config; charset="utf-8"`, hostKey.cloudConfig()},
+		}, parseUserData(t, userData))
+	})
+
+	t.Run("user data parts", func(t *testing.T) {
+		config := &Config{
+			UserDataParts: []userDataPart{{Type: "shell-script", Content: "#!/bin/sh\necho hello\n"}},
+		}
+
+		userData, err := renderUserData(config, "fsn1", "", hostKey)
+		require.NoError(t, err)
+
+		assert.Equal(t, [][2]string{
+			{`text/x-shellscript; charset="utf-8"`, "#!/bin/sh\necho hello\n"},
+			{`text/cloud-config; charset="utf-8"`, hostKey.cloudConfig()},
+		}, parseUserData(t, userData))
+	})
+
+	t.Run("multipart user data", func(t *testing.T) {
+		_, err := renderUserData(&Config{}, "fsn1", "MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=\"x\"\n", hostKey)
+		assert.EqualError(t, err, "user data is already a MIME multipart document, use user_data_parts instead")
+	})
+}
+
+func TestConfigPrepareUserDataParts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "valid",
+			raw: map[string]interface{}{
+				"user_data_parts": []map[string]interface{}{
+					{"type": "cloud-config", "content": "#cloud-config\nhostname: {{ .ServerName }}\n"},
+				},
+			},
+		},
+		{
+			name: "with user_data",
+			raw: map[string]interface{}{
+				"user_data": "#cloud-config\n",
+				"user_data_parts": []map[string]interface{}{
+					{"type": "cloud-config", "content": "#cloud-config\n"},
+				},
+			},
+			wantErr: "user_data_parts cannot be used with user_data or user_data_file",
+		},
+		{
+			name: "unknown type",
+			raw: map[string]interface{}{
+				"user_data_parts": []map[string]interface{}{
+					{"type": "boothook", "content": "#!/bin/sh\n"},
+				},
+			},
+			wantErr: "user_data_parts[0]: type must be one of cloud-config, include, shell-script",
+		},
+		{
+			name: "missing content",
+			raw: map[string]interface{}{
+				"user_data_parts": []map[string]interface{}{
+					{"type": "shell-script"},
+				},
+			},
+			wantErr: "user_data_parts[0]: one of content or file is required",
+		},
+		{
+			name: "invalid template",
+			raw: map[string]interface{}{
+				"user_data_parts": []map[string]interface{}{
+					{"type": "shell-script", "content": "#!/bin/sh\necho {{ .Unknown }}\n"},
+				},
+			},
+			wantErr: "user_data_parts[0]: could not render template",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+			tc.raw["location"] = "fsn1"
+			tc.raw["server_type"] = "cpx22"
+			tc.raw["image"] = "debian-12"
+			tc.raw["ssh_username"] = "root"
+
+			config := &Config{}
+			_, err := config.Prepare(tc.raw)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
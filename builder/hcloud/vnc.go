@@ -0,0 +1,390 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"context"
+	"crypto/des" //nolint:gosec // DES is mandated by the VNC authentication scheme
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+	"net/url"
+	"sync"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+)
+
+// RFB protocol constants, see https://www.rfc-editor.org/rfc/rfc6143
+const (
+	rfbProtocolVersion = "RFB 003.008\n"
+
+	rfbSecurityTypeNone    uint8 = 1
+	rfbSecurityTypeVNCAuth uint8 = 2
+
+	rfbClientMessageSetPixelFormat           uint8 = 0
+	rfbClientMessageSetEncodings             uint8 = 2
+	rfbClientMessageFramebufferUpdateRequest uint8 = 3
+	rfbClientMessageKeyEvent                 uint8 = 4
+
+	rfbServerMessageFramebufferUpdate   uint8 = 0
+	rfbServerMessageSetColourMapEntries uint8 = 1
+	rfbServerMessageBell                uint8 = 2
+	rfbServerMessageServerCutText       uint8 = 3
+
+	rfbEncodingRaw int32 = 0
+)
+
+// vncClient is a minimal RFB client, only able to send key events to the
+// server and to capture the framebuffer.
+type vncClient struct {
+	conn io.ReadWriteCloser
+	mu   sync.Mutex
+
+	Width  uint16
+	Height uint16
+	Name   string
+}
+
+// requestVNC requests a VNC console for the server, and connects to it.
+func requestVNC(ctx context.Context, client *hcloud.Client, serverID int64) (*vncClient, error) {
+	result, _, err := client.Server.RequestConsole(ctx, &hcloud.Server{ID: serverID})
+	if err != nil {
+		return nil, fmt.Errorf("could not request server console: %w", err)
+	}
+	if err := client.Action.WaitFor(ctx, result.Action); err != nil {
+		return nil, fmt.Errorf("could not request server console: %w", err)
+	}
+
+	vnc, err := dialVNC(ctx, result.WSSURL, result.Password)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to server console: %w", err)
+	}
+	return vnc, nil
+}
+
+// dialVNC opens a VNC connection over the websocket URL returned by the
+// Hetzner Cloud console API.
+func dialVNC(ctx context.Context, wssURL, password string) (*vncClient, error) {
+	conn, err := dialConsole(ctx, wssURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newVNCClient(conn, password)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// dialConsole opens the websocket connection transporting the RFB protocol.
+func dialConsole(ctx context.Context, wssURL string) (*websocket.Conn, error) {
+	location, err := url.Parse(wssURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid console url: %w", err)
+	}
+
+	origin := &url.URL{Scheme: "https", Host: location.Host}
+	if location.Scheme == "ws" {
+		origin.Scheme = "http"
+	}
+
+	config, err := websocket.NewConfig(location.String(), origin.String())
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = []string{"binary"}
+
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.PayloadType = websocket.BinaryFrame
+	return conn, nil
+}
+
+// newVNCClient performs the RFB handshake on the connection.
+func newVNCClient(conn io.ReadWriteCloser, password string) (*vncClient, error) {
+	c := &vncClient{conn: conn}
+
+	// Protocol version
+	version := make([]byte, len(rfbProtocolVersion))
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return nil, fmt.Errorf("could not read protocol version: %w", err)
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version), "RFB %03d.%03d\n", &major, &minor); err != nil {
+		return nil, fmt.Errorf("invalid protocol version %q", version)
+	}
+	if major < 3 || (major == 3 && minor < 8) {
+		return nil, fmt.Errorf("unsupported protocol version %d.%d", major, minor)
+	}
+	if _, err := conn.Write([]byte(rfbProtocolVersion)); err != nil {
+		return nil, err
+	}
+
+	// Security
+	var count uint8
+	if err := binary.Read(conn, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("could not read security types: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("connection refused by server: %s", c.readReason())
+	}
+	securityTypes := make([]uint8, count)
+	if _, err := io.ReadFull(conn, securityTypes); err != nil {
+		return nil, fmt.Errorf("could not read security types: %w", err)
+	}
+
+	securityType, err := selectSecurityType(securityTypes, password)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{securityType}); err != nil {
+		return nil, err
+	}
+
+	if securityType == rfbSecurityTypeVNCAuth {
+		challenge := make([]byte, 16)
+		if _, err := io.ReadFull(conn, challenge); err != nil {
+			return nil, fmt.Errorf("could not read authentication challenge: %w", err)
+		}
+		response, err := vncAuthResponse(challenge, password)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := conn.Write(response); err != nil {
+			return nil, err
+		}
+	}
+
+	var result uint32
+	if err := binary.Read(conn, binary.BigEndian, &result); err != nil {
+		return nil, fmt.Errorf("could not read security result: %w", err)
+	}
+	if result != 0 {
+		return nil, fmt.Errorf("authentication failed: %s", c.readReason())
+	}
+
+	// Initialization, request a shared session
+	if _, err := conn.Write([]byte{1}); err != nil {
+		return nil, err
+	}
+
+	var serverInit struct {
+		Width       uint16
+		Height      uint16
+		PixelFormat [16]byte
+		NameLength  uint32
+	}
+	if err := binary.Read(conn, binary.BigEndian, &serverInit); err != nil {
+		return nil, fmt.Errorf("could not read server init: %w", err)
+	}
+	name := make([]byte, serverInit.NameLength)
+	if _, err := io.ReadFull(conn, name); err != nil {
+		return nil, fmt.Errorf("could not read server init: %w", err)
+	}
+
+	c.Width = serverInit.Width
+	c.Height = serverInit.Height
+	c.Name = string(name)
+
+	return c, nil
+}
+
+func selectSecurityType(securityTypes []uint8, password string) (uint8, error) {
+	for _, securityType := range securityTypes {
+		if securityType == rfbSecurityTypeVNCAuth && password != "" {
+			return securityType, nil
+		}
+	}
+	for _, securityType := range securityTypes {
+		if securityType == rfbSecurityTypeNone {
+			return securityType, nil
+		}
+	}
+	return 0, fmt.Errorf("no supported security type offered by server: %v", securityTypes)
+}
+
+// vncAuthResponse encrypts the challenge with the password, using the DES key
+// derivation of the VNC authentication scheme, where the bits of each key byte
+// are reversed.
+func vncAuthResponse(challenge []byte, password string) ([]byte, error) {
+	key := make([]byte, 8)
+	copy(key, password)
+	for i := range key {
+		key[i] = bits.Reverse8(key[i])
+	}
+
+	block, err := des.NewCipher(key) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]byte, len(challenge))
+	for i := 0; i < len(challenge); i += block.BlockSize() {
+		block.Encrypt(response[i:], challenge[i:])
+	}
+	return response, nil
+}
+
+// readReason reads the reason string sent by the server on failures.
+func (c *vncClient) readReason() string {
+	var length uint32
+	if err := binary.Read(c.conn, binary.BigEndian, &length); err != nil {
+		return "unknown reason"
+	}
+	reason := make([]byte, length)
+	if _, err := io.ReadFull(c.conn, reason); err != nil {
+		return "unknown reason"
+	}
+	return string(reason)
+}
+
+// KeyEvent sends a key press or release to the server. It implements the
+// [bootcommand.VNCKeyEvent] interface.
+func (c *vncClient) KeyEvent(key uint32, down bool) error {
+	msg := make([]byte, 8)
+	msg[0] = rfbClientMessageKeyEvent
+	if down {
+		msg[1] = 1
+	}
+	binary.BigEndian.PutUint32(msg[4:], key)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := c.conn.Write(msg)
+	return err
+}
+
+// Screenshot requests a full update of the framebuffer, and returns it as an
+// image.
+func (c *vncClient) Screenshot(ctx context.Context) (*image.RGBA, error) {
+	// Interrupt pending reads when the context is done
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+
+	img, err := c.screenshot()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return img, err
+}
+
+func (c *vncClient) screenshot() (*image.RGBA, error) {
+	// 32 bits true color pixels, with the blue, green and red channels in the
+	// first, second and third bytes
+	setPixelFormat := []byte{
+		rfbClientMessageSetPixelFormat, 0, 0, 0,
+		32, 24, 0, 1, 0, 255, 0, 255, 0, 255, 16, 8, 0, 0, 0, 0,
+	}
+	setEncodings := []byte{rfbClientMessageSetEncodings, 0, 0, 1, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(setEncodings[4:], uint32(rfbEncodingRaw))
+
+	updateRequest := make([]byte, 10)
+	updateRequest[0] = rfbClientMessageFramebufferUpdateRequest
+	binary.BigEndian.PutUint16(updateRequest[6:], c.Width)
+	binary.BigEndian.PutUint16(updateRequest[8:], c.Height)
+
+	c.mu.Lock()
+	for _, msg := range [][]byte{setPixelFormat, setEncodings, updateRequest} {
+		if _, err := c.conn.Write(msg); err != nil {
+			c.mu.Unlock()
+			return nil, err
+		}
+	}
+	c.mu.Unlock()
+
+	img := image.NewRGBA(image.Rect(0, 0, int(c.Width), int(c.Height)))
+	for {
+		var msgType uint8
+		if err := binary.Read(c.conn, binary.BigEndian, &msgType); err != nil {
+			return nil, err
+		}
+
+		switch msgType {
+		case rfbServerMessageFramebufferUpdate:
+			var header struct {
+				Padding uint8
+				Count   uint16
+			}
+			if err := binary.Read(c.conn, binary.BigEndian, &header); err != nil {
+				return nil, err
+			}
+			for range header.Count {
+				if err := c.readRectangle(img); err != nil {
+					return nil, err
+				}
+			}
+			return img, nil
+
+		case rfbServerMessageSetColourMapEntries:
+			var header struct {
+				Padding    uint8
+				FirstColor uint16
+				Count      uint16
+			}
+			if err := binary.Read(c.conn, binary.BigEndian, &header); err != nil {
+				return nil, err
+			}
+			if _, err := io.CopyN(io.Discard, c.conn, int64(header.Count)*6); err != nil {
+				return nil, err
+			}
+
+		case rfbServerMessageBell:
+
+		case rfbServerMessageServerCutText:
+			var header struct {
+				Padding [3]uint8
+				Length  uint32
+			}
+			if err := binary.Read(c.conn, binary.BigEndian, &header); err != nil {
+				return nil, err
+			}
+			if _, err := io.CopyN(io.Discard, c.conn, int64(header.Length)); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, fmt.Errorf("unsupported server message type %d", msgType)
+		}
+	}
+}
+
+func (c *vncClient) readRectangle(img *image.RGBA) error {
+	var rect struct {
+		X, Y          uint16
+		Width, Height uint16
+		Encoding      int32
+	}
+	if err := binary.Read(c.conn, binary.BigEndian, &rect); err != nil {
+		return err
+	}
+	if rect.Encoding != rfbEncodingRaw {
+		return fmt.Errorf("unsupported encoding %d", rect.Encoding)
+	}
+
+	pixels := make([]byte, int(rect.Width)*int(rect.Height)*4)
+	if _, err := io.ReadFull(c.conn, pixels); err != nil {
+		return err
+	}
+	for y := range int(rect.Height) {
+		for x := range int(rect.Width) {
+			p := pixels[(y*int(rect.Width)+x)*4:]
+			img.SetRGBA(int(rect.X)+x, int(rect.Y)+y, color.RGBA{R: p[2], G: p[1], B: p[0], A: 255})
+		}
+	}
+	return nil
+}
+
+func (c *vncClient) Close() error {
+	return c.conn.Close()
+}
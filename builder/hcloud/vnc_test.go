@@ -0,0 +1,226 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/des"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	vncTestWidth  = 4
+	vncTestHeight = 2
+)
+
+// vncTestServer is a stand-in for the Hetzner Cloud websocket VNC console.
+type vncTestServer struct {
+	*httptest.Server
+
+	Password string
+
+	mu        sync.Mutex
+	keyEvents []vncTestKeyEvent
+	done      chan struct{}
+}
+
+type vncTestKeyEvent struct {
+	Key  uint32
+	Down bool
+}
+
+func newVNCTestServer(t *testing.T, password string) *vncTestServer {
+	t.Helper()
+
+	s := &vncTestServer{Password: password, done: make(chan struct{})}
+	s.Server = httptest.NewServer(websocket.Handler(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// URL returns the websocket URL of the server.
+func (s *vncTestServer) URL() string {
+	return strings.Replace(s.Server.URL, "http://", "ws://", 1)
+}
+
+// KeyEvents waits for the client to disconnect and returns the received key
+// events.
+func (s *vncTestServer) KeyEvents() []vncTestKeyEvent {
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keyEvents
+}
+
+func (s *vncTestServer) handle(conn *websocket.Conn) {
+	defer close(s.done)
+	conn.PayloadType = websocket.BinaryFrame
+
+	if !s.handshake(conn) {
+		return
+	}
+
+	for {
+		msgType := make([]byte, 1)
+		if _, err := io.ReadFull(conn, msgType); err != nil {
+			return
+		}
+
+		switch msgType[0] {
+		case rfbClientMessageSetPixelFormat:
+			io.CopyN(io.Discard, conn, 19)
+
+		case rfbClientMessageSetEncodings:
+			var header struct {
+				Padding uint8
+				Count   uint16
+			}
+			binary.Read(conn, binary.BigEndian, &header)
+			io.CopyN(io.Discard, conn, int64(header.Count)*4)
+
+		case rfbClientMessageFramebufferUpdateRequest:
+			io.CopyN(io.Discard, conn, 9)
+
+			// A bell, followed by a single raw rectangle covering the screen
+			conn.Write([]byte{rfbServerMessageBell})
+			conn.Write([]byte{rfbServerMessageFramebufferUpdate, 0, 0, 1})
+			binary.Write(conn, binary.BigEndian, []uint16{0, 0, vncTestWidth, vncTestHeight})
+			binary.Write(conn, binary.BigEndian, rfbEncodingRaw)
+			for i := range vncTestWidth * vncTestHeight {
+				conn.Write([]byte{byte(i), 0x00, 0xff, 0x00})
+			}
+
+		case rfbClientMessageKeyEvent:
+			msg := make([]byte, 7)
+			if _, err := io.ReadFull(conn, msg); err != nil {
+				return
+			}
+
+			s.mu.Lock()
+			s.keyEvents = append(s.keyEvents, vncTestKeyEvent{
+				Key:  binary.BigEndian.Uint32(msg[3:]),
+				Down: msg[0] == 1,
+			})
+			s.mu.Unlock()
+
+		default:
+			return
+		}
+	}
+}
+
+func (s *vncTestServer) handshake(conn io.ReadWriter) bool {
+	conn.Write([]byte(rfbProtocolVersion))
+	version := make([]byte, len(rfbProtocolVersion))
+	if _, err := io.ReadFull(conn, version); err != nil {
+		return false
+	}
+
+	conn.Write([]byte{1, rfbSecurityTypeVNCAuth})
+	securityType := make([]byte, 1)
+	if _, err := io.ReadFull(conn, securityType); err != nil {
+		return false
+	}
+
+	challenge := []byte("0123456789abcdef")
+	conn.Write(challenge)
+	response := make([]byte, 16)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return false
+	}
+
+	expected, _ := vncAuthResponse(challenge, s.Password)
+	if !bytes.Equal(expected, response) {
+		reason := "invalid password"
+		binary.Write(conn, binary.BigEndian, uint32(1))
+		binary.Write(conn, binary.BigEndian, uint32(len(reason)))
+		conn.Write([]byte(reason))
+		return false
+	}
+	binary.Write(conn, binary.BigEndian, uint32(0))
+
+	shared := make([]byte, 1)
+	if _, err := io.ReadFull(conn, shared); err != nil {
+		return false
+	}
+
+	name := "dummy-server"
+	binary.Write(conn, binary.BigEndian, uint16(vncTestWidth))
+	binary.Write(conn, binary.BigEndian, uint16(vncTestHeight))
+	conn.Write(make([]byte, 16))
+	binary.Write(conn, binary.BigEndian, uint32(len(name)))
+	conn.Write([]byte(name))
+	return true
+}
+
+func TestVNCClient(t *testing.T) {
+	server := newVNCTestServer(t, "secret")
+
+	client, err := dialVNC(context.Background(), server.URL(), "secret")
+	require.NoError(t, err)
+
+	assert.Equal(t, uint16(vncTestWidth), client.Width)
+	assert.Equal(t, uint16(vncTestHeight), client.Height)
+	assert.Equal(t, "dummy-server", client.Name)
+
+	require.NoError(t, client.KeyEvent(0x61, true))
+	require.NoError(t, client.KeyEvent(0x61, false))
+	require.NoError(t, client.Close())
+
+	assert.Equal(t, []vncTestKeyEvent{
+		{Key: 0x61, Down: true},
+		{Key: 0x61, Down: false},
+	}, server.KeyEvents())
+}
+
+func TestVNCClient_Screenshot(t *testing.T) {
+	server := newVNCTestServer(t, "secret")
+
+	client, err := dialVNC(context.Background(), server.URL(), "secret")
+	require.NoError(t, err)
+	defer client.Close()
+
+	img, err := client.Screenshot(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, image.Rect(0, 0, vncTestWidth, vncTestHeight), img.Bounds())
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0x00, B: 0x00, A: 0xff}, img.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0x00, B: 0x05, A: 0xff}, img.RGBAAt(1, 1))
+}
+
+func TestVNCClient_invalidPassword(t *testing.T) {
+	server := newVNCTestServer(t, "secret")
+
+	_, err := dialVNC(context.Background(), server.URL(), "wrong")
+	assert.EqualError(t, err, "authentication failed: invalid password")
+}
+
+func TestVNCAuthResponse(t *testing.T) {
+	challenge := []byte("0123456789abcdef")
+
+	response, err := vncAuthResponse(challenge, "\x01\x02")
+	require.NoError(t, err)
+
+	// The bits of each key byte are reversed, the key is padded with zeros
+	block, err := des.NewCipher([]byte{0x80, 0x40, 0, 0, 0, 0, 0, 0})
+	require.NoError(t, err)
+	expected := make([]byte, 16)
+	block.Encrypt(expected[:8], challenge[:8])
+	block.Encrypt(expected[8:], challenge[8:])
+
+	assert.Equal(t, expected, response)
+}
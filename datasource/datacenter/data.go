@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceOutput,DatacenterOutput,Config
+
+package datacenter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+type Config struct {
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	Location    string `mapstructure:"location"`
+	NetworkZone string `mapstructure:"network_zone"`
+	ServerType  string `mapstructure:"server_type"`
+}
+
+type Datasource struct {
+	config Config
+}
+
+type DatasourceOutput struct {
+	Datacenters []DatacenterOutput `mapstructure:"datacenters"`
+	Locations   []string           `mapstructure:"locations"`
+}
+
+type DatacenterOutput struct {
+	Location             string   `mapstructure:"location"`
+	NetworkZone          string   `mapstructure:"network_zone"`
+	AvailableServerTypes []string `mapstructure:"available_server_types"`
+	SupportedServerTypes []string `mapstructure:"supported_server_types"`
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	if es := d.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+// Execute lists the locations and the server types that can be created in
+// them. The Datacenters API was removed from the Hetzner Cloud API, the
+// availability is therefore reported per location, using the locations listed
+// in each server type.
+func (d *Datasource) Execute() (cty.Value, error) {
+	ctx := context.TODO()
+	client := d.config.NewClient()
+
+	locations, err := client.Location.All(ctx)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not fetch locations: %w", err)
+	}
+
+	serverTypes, err := client.ServerType.All(ctx)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not fetch server types: %w", err)
+	}
+
+	datacenters := make(map[string]*DatacenterOutput, len(locations))
+	for _, location := range locations {
+		datacenters[location.Name] = &DatacenterOutput{
+			Location:             location.Name,
+			NetworkZone:          string(location.NetworkZone),
+			AvailableServerTypes: []string{},
+			SupportedServerTypes: []string{},
+		}
+	}
+	for _, serverType := range serverTypes {
+		for _, serverTypeLocation := range serverType.Locations {
+			if serverTypeLocation.Location == nil {
+				continue
+			}
+			datacenter, ok := datacenters[serverTypeLocation.Location.Name]
+			if !ok {
+				continue
+			}
+			datacenter.SupportedServerTypes = append(datacenter.SupportedServerTypes, serverType.Name)
+			if serverTypeLocation.Available {
+				datacenter.AvailableServerTypes = append(datacenter.AvailableServerTypes, serverType.Name)
+			}
+		}
+	}
+
+	output := DatasourceOutput{
+		Datacenters: []DatacenterOutput{},
+		Locations:   []string{},
+	}
+	for _, location := range locations {
+		datacenter := datacenters[location.Name]
+		if d.config.Location != "" && datacenter.Location != d.config.Location {
+			continue
+		}
+		if d.config.NetworkZone != "" && datacenter.NetworkZone != d.config.NetworkZone {
+			continue
+		}
+		if d.config.ServerType != "" && !slices.Contains(datacenter.AvailableServerTypes, d.config.ServerType) {
+			continue
+		}
+		output.Datacenters = append(output.Datacenters, *datacenter)
+		output.Locations = append(output.Locations, datacenter.Location)
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
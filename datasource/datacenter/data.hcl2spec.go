@@ -0,0 +1,95 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package datacenter
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	HCloudToken  *string `mapstructure:"token" cty:"token" hcl:"token"`
+	Endpoint     *string `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	PollInterval *string `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
+	Location     *string `mapstructure:"location" cty:"location" hcl:"location"`
+	NetworkZone  *string `mapstructure:"network_zone" cty:"network_zone" hcl:"network_zone"`
+	ServerType   *string `mapstructure:"server_type" cty:"server_type" hcl:"server_type"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"token":         &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"endpoint":      &hcldec.AttrSpec{Name: "endpoint", Type: cty.String, Required: false},
+		"poll_interval": &hcldec.AttrSpec{Name: "poll_interval", Type: cty.String, Required: false},
+		"location":      &hcldec.AttrSpec{Name: "location", Type: cty.String, Required: false},
+		"network_zone":  &hcldec.AttrSpec{Name: "network_zone", Type: cty.String, Required: false},
+		"server_type":   &hcldec.AttrSpec{Name: "server_type", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatDatacenterOutput is an auto-generated flat version of DatacenterOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatacenterOutput struct {
+	Location             *string  `mapstructure:"location" cty:"location" hcl:"location"`
+	NetworkZone          *string  `mapstructure:"network_zone" cty:"network_zone" hcl:"network_zone"`
+	AvailableServerTypes []string `mapstructure:"available_server_types" cty:"available_server_types" hcl:"available_server_types"`
+	SupportedServerTypes []string `mapstructure:"supported_server_types" cty:"supported_server_types" hcl:"supported_server_types"`
+}
+
+// FlatMapstructure returns a new FlatDatacenterOutput.
+// FlatDatacenterOutput is an auto-generated flat version of DatacenterOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatacenterOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatacenterOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatacenterOutput.
+// This spec is used by HCL to read the fields of DatacenterOutput.
+// The decoded values from this spec will then be applied to a FlatDatacenterOutput.
+func (*FlatDatacenterOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"location":               &hcldec.AttrSpec{Name: "location", Type: cty.String, Required: false},
+		"network_zone":           &hcldec.AttrSpec{Name: "network_zone", Type: cty.String, Required: false},
+		"available_server_types": &hcldec.AttrSpec{Name: "available_server_types", Type: cty.List(cty.String), Required: false},
+		"supported_server_types": &hcldec.AttrSpec{Name: "supported_server_types", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	Datacenters []FlatDatacenterOutput `mapstructure:"datacenters" cty:"datacenters" hcl:"datacenters"`
+	Locations   []string               `mapstructure:"locations" cty:"locations" hcl:"locations"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"datacenters": &hcldec.BlockListSpec{TypeName: "datacenters", Nested: hcldec.ObjectSpec((*FlatDatacenterOutput)(nil).HCL2Spec())},
+		"locations":   &hcldec.AttrSpec{Name: "locations", Type: cty.List(cty.String), Required: false},
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package datacenter
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+var testRequests = []mockutil.Request{
+	{Method: "GET", Path: "/locations?page=1&per_page=50",
+		Status: 200,
+		JSONRaw: `{
+			"locations": [
+				{ "id": 1, "name": "fsn1", "network_zone": "eu-central" },
+				{ "id": 2, "name": "nbg1", "network_zone": "eu-central" },
+				{ "id": 4, "name": "ash", "network_zone": "us-east" }
+			],
+			"meta": { "pagination": { "page": 1 }}
+		}`,
+	},
+	{Method: "GET", Path: "/server_types?page=1&per_page=50",
+		Status: 200,
+		JSONRaw: `{
+			"server_types": [
+				{
+					"id": 109,
+					"name": "cpx22",
+					"architecture": "x86",
+					"locations": [
+						{ "id": 1, "name": "fsn1", "available": false },
+						{ "id": 2, "name": "nbg1", "available": true }
+					]
+				},
+				{
+					"id": 45,
+					"name": "cax11",
+					"architecture": "arm",
+					"locations": [
+						{ "id": 1, "name": "fsn1", "available": true }
+					]
+				}
+			],
+			"meta": { "pagination": { "page": 1 }}
+		}`,
+	},
+}
+
+func TestDatasourceExecute(t *testing.T) {
+	testCases := []struct {
+		name     string
+		raw      map[string]interface{}
+		wantFunc func(*testing.T, cty.Value)
+	}{
+		{
+			name: "all",
+			raw:  map[string]interface{}{},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				locations := value.GetAttr("locations").AsValueSlice()
+				require.Len(t, locations, 3)
+
+				datacenters := value.GetAttr("datacenters").AsValueSlice()
+				require.Len(t, datacenters, 3)
+
+				fsn1 := datacenters[0]
+				assert.Equal(t, "fsn1", fsn1.GetAttr("location").AsString())
+				assert.Equal(t, "eu-central", fsn1.GetAttr("network_zone").AsString())
+				assert.Equal(t,
+					[]cty.Value{cty.StringVal("cax11")},
+					fsn1.GetAttr("available_server_types").AsValueSlice())
+				assert.Equal(t,
+					[]cty.Value{cty.StringVal("cpx22"), cty.StringVal("cax11")},
+					fsn1.GetAttr("supported_server_types").AsValueSlice())
+
+				ash := datacenters[2]
+				assert.Equal(t, "ash", ash.GetAttr("location").AsString())
+				assert.Empty(t, ash.GetAttr("supported_server_types").AsValueSlice())
+			},
+		},
+		{
+			name: "with server type",
+			raw:  map[string]interface{}{"server_type": "cpx22"},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				assert.Equal(t,
+					[]cty.Value{cty.StringVal("nbg1")},
+					value.GetAttr("locations").AsValueSlice())
+			},
+		},
+		{
+			name: "with network zone",
+			raw:  map[string]interface{}{"network_zone": "us-east"},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				assert.Equal(t,
+					[]cty.Value{cty.StringVal("ash")},
+					value.GetAttr("locations").AsValueSlice())
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, testRequests))
+			defer server.Close()
+
+			tc.raw["token"] = "dummy"
+			tc.raw["endpoint"] = server.URL
+
+			d := &Datasource{}
+			require.NoError(t, d.Configure(tc.raw))
+
+			value, err := d.Execute()
+			require.NoError(t, err)
+			tc.wantFunc(t, value)
+		})
+	}
+}
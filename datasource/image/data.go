@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceOutput,Config
+
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+type Config struct {
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	Name         string   `mapstructure:"name"`
+	WithSelector []string `mapstructure:"with_selector"`
+	MostRecent   bool     `mapstructure:"most_recent"`
+	Architecture string   `mapstructure:"architecture"`
+}
+
+type Datasource struct {
+	config Config
+}
+
+type DatasourceOutput struct {
+	ID           int64             `mapstructure:"id"`
+	Name         string            `mapstructure:"name"`
+	Description  string            `mapstructure:"description"`
+	Type         string            `mapstructure:"type"`
+	Architecture string            `mapstructure:"architecture"`
+	OSFlavor     string            `mapstructure:"os_flavor"`
+	OSVersion    string            `mapstructure:"os_version"`
+	DiskSize     float64           `mapstructure:"disk_size"`
+	ImageSize    float64           `mapstructure:"image_size"`
+	Labels       map[string]string `mapstructure:"labels"`
+	Created      string            `mapstructure:"created"`
+	Deprecated   string            `mapstructure:"deprecated"`
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	if d.config.Architecture == "" && d.config.Name != "" {
+		d.config.Architecture = string(hcloud.ArchitectureX86)
+	}
+
+	var errs *packersdk.MultiError
+	if es := d.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if d.config.Name == "" && len(d.config.WithSelector) == 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("name or with_selector is required"))
+	} else if d.config.Name != "" && len(d.config.WithSelector) > 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("only one of name or with_selector can be specified"))
+	}
+
+	switch hcloud.Architecture(d.config.Architecture) {
+	case "", hcloud.ArchitectureX86, hcloud.ArchitectureARM:
+	default:
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("architecture must be one of %q or %q", hcloud.ArchitectureX86, hcloud.ArchitectureARM))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	ctx := context.TODO()
+	client := d.config.NewClient()
+
+	var image *hcloud.Image
+	var err error
+	if d.config.Name != "" {
+		image, _, err = client.Image.GetForArchitecture(ctx, d.config.Name, hcloud.Architecture(d.config.Architecture))
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not fetch image '%s': %w", d.config.Name, err)
+		}
+		if image == nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not find image '%s'", d.config.Name)
+		}
+	} else {
+		image, err = hcloudbuilder.GetImageWithSelectors(ctx, client,
+			d.config.WithSelector, d.config.MostRecent, hcloud.Architecture(d.config.Architecture))
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not find image: %w", err)
+		}
+	}
+
+	output := DatasourceOutput{
+		ID:           image.ID,
+		Name:         image.Name,
+		Description:  image.Description,
+		Type:         string(image.Type),
+		Architecture: string(image.Architecture),
+		OSFlavor:     image.OSFlavor,
+		OSVersion:    image.OSVersion,
+		DiskSize:     float64(image.DiskSize),
+		ImageSize:    float64(image.ImageSize),
+		Labels:       image.Labels,
+		Created:      formatTime(image.Created),
+		Deprecated:   formatTime(image.Deprecated),
+	}
+	if output.Labels == nil {
+		output.Labels = map[string]string{}
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,88 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package image
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	HCloudToken  *string  `mapstructure:"token" cty:"token" hcl:"token"`
+	Endpoint     *string  `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	PollInterval *string  `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
+	Name         *string  `mapstructure:"name" cty:"name" hcl:"name"`
+	WithSelector []string `mapstructure:"with_selector" cty:"with_selector" hcl:"with_selector"`
+	MostRecent   *bool    `mapstructure:"most_recent" cty:"most_recent" hcl:"most_recent"`
+	Architecture *string  `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"token":         &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"endpoint":      &hcldec.AttrSpec{Name: "endpoint", Type: cty.String, Required: false},
+		"poll_interval": &hcldec.AttrSpec{Name: "poll_interval", Type: cty.String, Required: false},
+		"name":          &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"with_selector": &hcldec.AttrSpec{Name: "with_selector", Type: cty.List(cty.String), Required: false},
+		"most_recent":   &hcldec.AttrSpec{Name: "most_recent", Type: cty.Bool, Required: false},
+		"architecture":  &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	ID           *int64            `mapstructure:"id" cty:"id" hcl:"id"`
+	Name         *string           `mapstructure:"name" cty:"name" hcl:"name"`
+	Description  *string           `mapstructure:"description" cty:"description" hcl:"description"`
+	Type         *string           `mapstructure:"type" cty:"type" hcl:"type"`
+	Architecture *string           `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+	OSFlavor     *string           `mapstructure:"os_flavor" cty:"os_flavor" hcl:"os_flavor"`
+	OSVersion    *string           `mapstructure:"os_version" cty:"os_version" hcl:"os_version"`
+	DiskSize     *float64          `mapstructure:"disk_size" cty:"disk_size" hcl:"disk_size"`
+	ImageSize    *float64          `mapstructure:"image_size" cty:"image_size" hcl:"image_size"`
+	Labels       map[string]string `mapstructure:"labels" cty:"labels" hcl:"labels"`
+	Created      *string           `mapstructure:"created" cty:"created" hcl:"created"`
+	Deprecated   *string           `mapstructure:"deprecated" cty:"deprecated" hcl:"deprecated"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"id":           &hcldec.AttrSpec{Name: "id", Type: cty.Number, Required: false},
+		"name":         &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"description":  &hcldec.AttrSpec{Name: "description", Type: cty.String, Required: false},
+		"type":         &hcldec.AttrSpec{Name: "type", Type: cty.String, Required: false},
+		"architecture": &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+		"os_flavor":    &hcldec.AttrSpec{Name: "os_flavor", Type: cty.String, Required: false},
+		"os_version":   &hcldec.AttrSpec{Name: "os_version", Type: cty.String, Required: false},
+		"disk_size":    &hcldec.AttrSpec{Name: "disk_size", Type: cty.Number, Required: false},
+		"image_size":   &hcldec.AttrSpec{Name: "image_size", Type: cty.Number, Required: false},
+		"labels":       &hcldec.AttrSpec{Name: "labels", Type: cty.Map(cty.String), Required: false},
+		"created":      &hcldec.AttrSpec{Name: "created", Type: cty.String, Required: false},
+		"deprecated":   &hcldec.AttrSpec{Name: "deprecated", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package image
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestDatasourceConfigure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "name",
+			raw:  map[string]interface{}{"name": "debian-12"},
+		},
+		{
+			name: "with_selector",
+			raw:  map[string]interface{}{"with_selector": []string{"app=web"}},
+		},
+		{
+			name:    "missing filter",
+			raw:     map[string]interface{}{},
+			wantErr: "name or with_selector is required",
+		},
+		{
+			name:    "both filters",
+			raw:     map[string]interface{}{"name": "debian-12", "with_selector": []string{"app=web"}},
+			wantErr: "only one of name or with_selector can be specified",
+		},
+		{
+			name:    "invalid architecture",
+			raw:     map[string]interface{}{"name": "debian-12", "architecture": "riscv"},
+			wantErr: "architecture must be one of",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			d := &Datasource{}
+			err := d.Configure(tc.raw)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatasourceExecute(t *testing.T) {
+	testCases := []struct {
+		name         string
+		raw          map[string]interface{}
+		wantRequests []mockutil.Request
+		wantErr      string
+		wantFunc     func(*testing.T, cty.Value)
+	}{
+		{
+			name: "by name",
+			raw:  map[string]interface{}{"name": "debian-12"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?architecture=x86&include_deprecated=true&name=debian-12",
+					Status: 200,
+					JSONRaw: `{
+						"images": [{
+							"id": 114690387,
+							"name": "debian-12",
+							"description": "Debian 12",
+							"type": "system",
+							"architecture": "x86",
+							"os_flavor": "debian",
+							"os_version": "12",
+							"disk_size": 5,
+							"created": "2023-06-13T06:00:02+00:00",
+							"labels": {}
+						}]
+					}`,
+				},
+			},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				id, _ := value.GetAttr("id").AsBigFloat().Int64()
+				assert.Equal(t, int64(114690387), id)
+				assert.Equal(t, "debian-12", value.GetAttr("name").AsString())
+				assert.Equal(t, "system", value.GetAttr("type").AsString())
+				assert.Equal(t, "debian", value.GetAttr("os_flavor").AsString())
+				assert.Equal(t, "12", value.GetAttr("os_version").AsString())
+				assert.Equal(t, "2023-06-13T06:00:02Z", value.GetAttr("created").AsString())
+				assert.Empty(t, value.GetAttr("deprecated").AsString())
+			},
+		},
+		{
+			name: "by name not found",
+			raw:  map[string]interface{}{"name": "debian-12", "architecture": "arm"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?architecture=arm&include_deprecated=true&name=debian-12",
+					Status:  200,
+					JSONRaw: `{ "images": [] }`,
+				},
+			},
+			wantErr: "Could not find image 'debian-12'",
+		},
+		{
+			name: "with selector most recent",
+			raw: map[string]interface{}{
+				"with_selector": []string{"app=web"},
+				"most_recent":   true,
+			},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?label_selector=app%3Dweb&page=1&per_page=50&status=available",
+					Status: 200,
+					JSONRaw: `{
+						"images": [
+							{ "id": 1, "type": "snapshot", "architecture": "x86", "created": "2024-01-01T00:00:00+00:00", "labels": { "app": "web" }},
+							{ "id": 2, "type": "snapshot", "architecture": "arm", "created": "2024-02-01T00:00:00+00:00", "labels": { "app": "web" }}
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+			},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				id, _ := value.GetAttr("id").AsBigFloat().Int64()
+				assert.Equal(t, int64(2), id)
+				assert.Equal(t, "arm", value.GetAttr("architecture").AsString())
+				assert.Equal(t, "web", value.GetAttr("labels").Index(cty.StringVal("app")).AsString())
+			},
+		},
+		{
+			name: "with selector multiple",
+			raw: map[string]interface{}{
+				"with_selector": []string{"app=web"},
+				"architecture":  "x86",
+			},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images?architecture=x86&label_selector=app%3Dweb&page=1&per_page=50&status=available",
+					Status: 200,
+					JSONRaw: `{
+						"images": [{ "id": 1 }, { "id": 2 }],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+			},
+			wantErr: `more than one image found for selector "app=web"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, tc.wantRequests))
+			defer server.Close()
+
+			tc.raw["token"] = "dummy"
+			tc.raw["endpoint"] = server.URL
+
+			d := &Datasource{}
+			require.NoError(t, d.Configure(tc.raw))
+
+			value, err := d.Execute()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tc.wantFunc(t, value)
+		})
+	}
+}
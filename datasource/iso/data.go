@@ -0,0 +1,157 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type DatasourceOutput,Config
+
+package iso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+type Config struct {
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	Name              string `mapstructure:"name"`
+	Architecture      string `mapstructure:"architecture"`
+	Type              string `mapstructure:"type"`
+	IncludeDeprecated bool   `mapstructure:"include_deprecated"`
+}
+
+type Datasource struct {
+	config Config
+}
+
+type DatasourceOutput struct {
+	ID           int64  `mapstructure:"id"`
+	Name         string `mapstructure:"name"`
+	Description  string `mapstructure:"description"`
+	Type         string `mapstructure:"type"`
+	Architecture string `mapstructure:"architecture"`
+	Deprecated   string `mapstructure:"deprecated"`
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	if es := d.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if d.config.Name == "" && d.config.Architecture == "" && d.config.Type == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("one of name, architecture or type is required"))
+	}
+
+	switch hcloud.Architecture(d.config.Architecture) {
+	case "", hcloud.ArchitectureX86, hcloud.ArchitectureARM:
+	default:
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("architecture must be one of %q or %q", hcloud.ArchitectureX86, hcloud.ArchitectureARM))
+	}
+
+	switch hcloud.ISOType(d.config.Type) {
+	case "", hcloud.ISOTypePublic, hcloud.ISOTypePrivate:
+	default:
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("type must be one of %q or %q", hcloud.ISOTypePublic, hcloud.ISOTypePrivate))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	ctx := context.TODO()
+	client := d.config.NewClient()
+
+	var isos []*hcloud.ISO
+	if id, err := strconv.ParseInt(d.config.Name, 10, 64); err == nil {
+		iso, _, err := client.ISO.GetByID(ctx, id)
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not fetch ISO '%d': %w", id, err)
+		}
+		if iso != nil {
+			isos = append(isos, iso)
+		}
+	} else {
+		opts := hcloud.ISOListOpts{Name: d.config.Name}
+		if d.config.Architecture != "" {
+			// Private ISOs do not have an architecture
+			opts.Architecture = []hcloud.Architecture{hcloud.Architecture(d.config.Architecture)}
+			opts.IncludeArchitectureWildcard = true
+		}
+		isos, err = client.ISO.AllWithOpts(ctx, opts)
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("Could not fetch ISOs: %w", err)
+		}
+	}
+
+	isos = slices.DeleteFunc(isos, func(iso *hcloud.ISO) bool { return !d.matches(iso) })
+	if len(isos) == 0 {
+		return cty.NullVal(cty.EmptyObject), errors.New("Could not find ISO matching the filters")
+	}
+	if len(isos) > 1 {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("More than one ISO (%d) found matching the filters", len(isos))
+	}
+	iso := isos[0]
+
+	output := DatasourceOutput{
+		ID:          iso.ID,
+		Name:        iso.Name,
+		Description: iso.Description,
+		Type:        string(iso.Type),
+	}
+	if iso.Architecture != nil {
+		output.Architecture = string(*iso.Architecture)
+	}
+	if iso.IsDeprecated() {
+		output.Deprecated = iso.DeprecationAnnounced().Format(time.RFC3339)
+	}
+
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// matches reports whether the ISO matches the architecture, type and
+// deprecation filters.
+func (d *Datasource) matches(iso *hcloud.ISO) bool {
+	if d.config.Type != "" && iso.Type != hcloud.ISOType(d.config.Type) {
+		return false
+	}
+	// Private ISOs do not have an architecture
+	if d.config.Architecture != "" && iso.Architecture != nil && *iso.Architecture != hcloud.Architecture(d.config.Architecture) {
+		return false
+	}
+	if !d.config.IncludeDeprecated && iso.IsDeprecated() {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,215 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package iso
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+)
+
+func TestDatasourceConfigure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "name",
+			raw:  map[string]interface{}{"name": "ubuntu-24.04.1-live-server-amd64.iso"},
+		},
+		{
+			name: "type",
+			raw:  map[string]interface{}{"type": "private"},
+		},
+		{
+			name:    "missing filters",
+			raw:     map[string]interface{}{},
+			wantErr: "one of name, architecture or type is required",
+		},
+		{
+			name:    "invalid type",
+			raw:     map[string]interface{}{"name": "custom.iso", "type": "shared"},
+			wantErr: "type must be one of",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			d := &Datasource{}
+			err := d.Configure(tc.raw)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatasourceExecute(t *testing.T) {
+	testCases := []struct {
+		name         string
+		raw          map[string]interface{}
+		wantRequests []mockutil.Request
+		wantErr      string
+		wantFunc     func(*testing.T, cty.Value)
+	}{
+		{
+			name: "by name",
+			raw:  map[string]interface{}{"name": "FreeBSD-14.1-RELEASE-amd64-dvd1.iso"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos?name=FreeBSD-14.1-RELEASE-amd64-dvd1.iso&page=1&per_page=50",
+					Status: 200,
+					JSONRaw: `{
+						"isos": [{
+							"id": 4711,
+							"name": "FreeBSD-14.1-RELEASE-amd64-dvd1.iso",
+							"description": "FreeBSD 14.1 x64",
+							"type": "public",
+							"architecture": "x86"
+						}]
+					}`,
+				},
+			},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				id, _ := value.GetAttr("id").AsBigFloat().Int64()
+				assert.Equal(t, int64(4711), id)
+				assert.Equal(t, "public", value.GetAttr("type").AsString())
+				assert.Equal(t, "x86", value.GetAttr("architecture").AsString())
+			},
+		},
+		{
+			name: "by name deprecated",
+			raw:  map[string]interface{}{"name": "old.iso"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos?name=old.iso&page=1&per_page=50",
+					Status: 200,
+					JSONRaw: `{
+						"isos": [{
+							"id": 4711,
+							"name": "old.iso",
+							"type": "public",
+							"architecture": "x86",
+							"deprecation": {
+								"announced": "2023-06-01T00:00:00+00:00",
+								"unavailable_after": "2023-09-01T00:00:00+00:00"
+							}
+						}]
+					}`,
+				},
+			},
+			wantErr: "Could not find ISO matching the filters",
+		},
+		{
+			name: "by name private with architecture",
+			raw:  map[string]interface{}{"name": "custom.iso", "type": "private", "architecture": "arm"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos?architecture=arm&include_architecture_wildcard=true&name=custom.iso&page=1&per_page=50",
+					Status: 200,
+					JSONRaw: `{
+						"isos": [{ "id": 2, "name": "custom.iso", "type": "private", "architecture": null }]
+					}`,
+				},
+			},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				id, _ := value.GetAttr("id").AsBigFloat().Int64()
+				assert.Equal(t, int64(2), id)
+				assert.Empty(t, value.GetAttr("architecture").AsString())
+			},
+		},
+		{
+			name: "by name other architecture",
+			raw:  map[string]interface{}{"name": "FreeBSD-14.1-RELEASE-amd64-dvd1.iso", "architecture": "arm"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos?architecture=arm&include_architecture_wildcard=true&name=FreeBSD-14.1-RELEASE-amd64-dvd1.iso&page=1&per_page=50",
+					Status: 200,
+					JSONRaw: `{
+						"isos": []
+					}`,
+				},
+			},
+			wantErr: "Could not find ISO matching the filters",
+		},
+		{
+			name: "by id",
+			raw:  map[string]interface{}{"name": "4711"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos/4711",
+					Status: 200,
+					JSONRaw: `{
+						"iso": { "id": 4711, "name": "FreeBSD-14.1-RELEASE-amd64-dvd1.iso", "type": "public", "architecture": "x86" }
+					}`,
+				},
+			},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				assert.Equal(t, "FreeBSD-14.1-RELEASE-amd64-dvd1.iso", value.GetAttr("name").AsString())
+			},
+		},
+		{
+			name: "by type",
+			raw:  map[string]interface{}{"type": "private"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos?page=1&per_page=50",
+					Status: 200,
+					JSONRaw: `{
+						"isos": [
+							{ "id": 1, "name": "FreeBSD-14.1-RELEASE-amd64-dvd1.iso", "type": "public", "architecture": "x86" },
+							{ "id": 2, "name": "custom.iso", "type": "private", "architecture": null }
+						]
+					}`,
+				},
+			},
+			wantFunc: func(t *testing.T, value cty.Value) {
+				id, _ := value.GetAttr("id").AsBigFloat().Int64()
+				assert.Equal(t, int64(2), id)
+			},
+		},
+		{
+			name: "by architecture ambiguous",
+			raw:  map[string]interface{}{"architecture": "x86", "type": "public"},
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/isos?architecture=x86&include_architecture_wildcard=true&page=1&per_page=50",
+					Status: 200,
+					JSONRaw: `{
+						"isos": [
+							{ "id": 1, "name": "FreeBSD-14.1-RELEASE-amd64-dvd1.iso", "type": "public", "architecture": "x86" },
+							{ "id": 3, "name": "ubuntu-24.04.1-live-server-amd64.iso", "type": "public", "architecture": "x86" },
+							{ "id": 2, "name": "custom.iso", "type": "private", "architecture": null }
+						]
+					}`,
+				},
+			},
+			wantErr: "More than one ISO (2) found matching the filters",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, tc.wantRequests))
+			defer server.Close()
+
+			tc.raw["token"] = "dummy"
+			tc.raw["endpoint"] = server.URL
+
+			d := &Datasource{}
+			require.NoError(t, d.Configure(tc.raw))
+
+			value, err := d.Execute()
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			tc.wantFunc(t, value)
+		})
+	}
+}
@@ -31,7 +31,8 @@ source "hcloud" "docker" {
     resize_rootfs: false
   EOF
 
-  ssh_username = "root"
+  ssh_username        = "root"
+  wait_for_cloud_init = true
 
   snapshot_name = "docker-{{ timestamp }}"
   snapshot_labels = {
@@ -42,11 +43,6 @@ source "hcloud" "docker" {
 build {
   sources = ["source.hcloud.docker"]
 
-  provisioner "shell" {
-    inline           = ["cloud-init status --wait --long"]
-    valid_exit_codes = [0, 2]
-  }
-
   provisioner "shell" {
     scripts = [
       "install.sh",
@@ -24,7 +24,8 @@ source "hcloud" "example" {
   server_type = "cpx12"
   server_name = "example-{{ timestamp }}"
 
-  ssh_username = "root"
+  ssh_username        = "root"
+  wait_for_cloud_init = true
 
   snapshot_name = "example-{{ timestamp }}"
   snapshot_labels = {
@@ -44,11 +45,6 @@ build {
 
   sources = ["source.hcloud.example"]
 
-  provisioner "shell" {
-    inline           = ["cloud-init status --wait --long"]
-    valid_exit_codes = [0, 2]
-  }
-
   provisioner "shell" {
     inline = ["echo 'Hello World!' > /var/log/packer.log"]
   }
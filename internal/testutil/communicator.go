@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package testutil provides helpers shared by the tests of the components.
+package testutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// LocalCommunicator runs the commands locally with bash, using the uploaded
+// files as scripts when they are run with bash.
+type LocalCommunicator struct {
+	packersdk.MockCommunicator
+
+	Files map[string]string
+	Dir   string
+}
+
+// NewLocalCommunicator returns a communicator running the commands in the
+// given directory, or in the current directory if empty.
+func NewLocalCommunicator(dir string) *LocalCommunicator {
+	return &LocalCommunicator{Files: map[string]string{}, Dir: dir}
+}
+
+func (c *LocalCommunicator) Upload(path string, r io.Reader, _ *os.FileInfo) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.Files[path] = string(data)
+	return nil
+}
+
+func (c *LocalCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	script, ok := c.Files[strings.TrimPrefix(cmd.Command, "bash ")]
+	if !ok {
+		script = cmd.Command
+	}
+
+	command := exec.CommandContext(ctx, "bash", "-c", script)
+	command.Dir = c.Dir
+	command.Stdin = cmd.Stdin
+	command.Stdout = cmd.Stdout
+	command.Stderr = cmd.Stderr
+
+	go func() {
+		err := command.Run()
+
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(err, &exitErr):
+			cmd.SetExited(exitErr.ExitCode())
+		case err != nil:
+			cmd.SetExited(1)
+		default:
+			cmd.SetExited(0)
+		}
+	}()
+	return nil
+}
@@ -10,12 +10,30 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/plugin"
 
 	"github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+	"github.com/hetznercloud/packer-plugin-hcloud/datasource/datacenter"
+	"github.com/hetznercloud/packer-plugin-hcloud/datasource/image"
+	"github.com/hetznercloud/packer-plugin-hcloud/datasource/iso"
+	hcloudexport "github.com/hetznercloud/packer-plugin-hcloud/post-processor/export"
+	hcloudimport "github.com/hetznercloud/packer-plugin-hcloud/post-processor/import"
+	snapshotlifecycle "github.com/hetznercloud/packer-plugin-hcloud/post-processor/snapshot-lifecycle"
+	snapshotretention "github.com/hetznercloud/packer-plugin-hcloud/post-processor/snapshot-retention"
+	hcloudpower "github.com/hetznercloud/packer-plugin-hcloud/provisioner/power"
+	hcloudsnapshot "github.com/hetznercloud/packer-plugin-hcloud/provisioner/snapshot"
 	"github.com/hetznercloud/packer-plugin-hcloud/version"
 )
 
 func main() {
 	pps := plugin.NewSet()
 	pps.RegisterBuilder(plugin.DEFAULT_NAME, new(hcloud.Builder))
+	pps.RegisterDatasource("datacenter", new(datacenter.Datasource))
+	pps.RegisterDatasource("image", new(image.Datasource))
+	pps.RegisterDatasource("iso", new(iso.Datasource))
+	pps.RegisterProvisioner("power", new(hcloudpower.Provisioner))
+	pps.RegisterProvisioner("snapshot", new(hcloudsnapshot.Provisioner))
+	pps.RegisterPostProcessor("export", new(hcloudexport.PostProcessor))
+	pps.RegisterPostProcessor("import", new(hcloudimport.PostProcessor))
+	pps.RegisterPostProcessor("snapshot-lifecycle", new(snapshotlifecycle.PostProcessor))
+	pps.RegisterPostProcessor("snapshot-retention", new(snapshotretention.PostProcessor))
 	pps.SetVersion(version.PluginVersion)
 	err := pps.Run()
 	if err != nil {
@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudexport
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const BuilderId = "hcloud.post-processor.export"
+
+type Artifact struct {
+	// The local files the snapshots were exported to
+	files []string
+
+	// The URLs of the uploaded S3 objects
+	urls []string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return a.files
+}
+
+func (a *Artifact) Id() string {
+	return strings.Join(a.files, ",")
+}
+
+func (a *Artifact) String() string {
+	if len(a.urls) > 0 {
+		return fmt.Sprintf("Snapshots were exported to: %s (uploaded to: %s)",
+			strings.Join(a.files, ", "), strings.Join(a.urls, ", "))
+	}
+	return fmt.Sprintf("Snapshots were exported to: %s", strings.Join(a.files, ", "))
+}
+
+func (a *Artifact) State(name string) interface{} {
+	if name == "s3_urls" {
+		return a.urls
+	}
+	return nil
+}
+
+// Destroy removes the local files, the uploaded S3 objects are kept.
+func (a *Artifact) Destroy() error {
+	var errs []error
+	for _, file := range a.files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,266 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,S3Config
+
+package hcloudexport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+const (
+	FormatRaw   = "raw"
+	FormatQCOW2 = "qcow2"
+	FormatVMDK  = "vmdk"
+
+	defaultOutput = "packer_{{ .SnapshotName }}_{{ .SnapshotID }}.{{ .Format }}"
+
+	// disk is the disk of the server, as seen from the rescue system.
+	disk = "/dev/sda"
+)
+
+var formats = []string{FormatRaw, FormatQCOW2, FormatVMDK}
+
+type Config struct {
+	common.PackerConfig        `mapstructure:",squash"`
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	Location           string            `mapstructure:"location"`
+	Locations          []string          `mapstructure:"locations"`
+	ServerType         string            `mapstructure:"server_type"`
+	Architectures      map[string]string `mapstructure:"architectures"`
+	Networks           []int64           `mapstructure:"networks"`
+	PublicIPv4         string            `mapstructure:"public_ipv4"`
+	PublicIPv4Disabled bool              `mapstructure:"public_ipv4_disabled"`
+	PublicIPv6         string            `mapstructure:"public_ipv6"`
+	PublicIPv6Disabled bool              `mapstructure:"public_ipv6_disabled"`
+
+	Format string    `mapstructure:"format"`
+	Output string    `mapstructure:"output"`
+	S3     *S3Config `mapstructure:"s3"`
+
+	ctx interpolate.Context
+}
+
+type S3Config struct {
+	Bucket         string `mapstructure:"bucket"`
+	Prefix         string `mapstructure:"prefix"`
+	Endpoint       string `mapstructure:"endpoint"`
+	Region         string `mapstructure:"region"`
+	AccessKey      string `mapstructure:"access_key"`
+	SecretKey      string `mapstructure:"secret_key"`
+	ForcePathStyle bool   `mapstructure:"force_path_style"`
+}
+
+type outputTemplateData struct {
+	SnapshotID   int64
+	SnapshotName string
+	Architecture string
+	Format       string
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hcloud-export",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"output",
+			},
+		},
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	// Defaults
+	if p.config.Format == "" {
+		p.config.Format = FormatRaw
+	}
+	if p.config.Output == "" {
+		p.config.Output = defaultOutput
+	}
+	if p.config.S3 != nil && p.config.S3.Region == "" {
+		p.config.S3.Region = "us-east-1"
+	}
+
+	var errs *packersdk.MultiError
+	if !slices.Contains(formats, p.config.Format) {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("format must be one of %s", strings.Join(formats, ", ")))
+	} else if p.config.Format != FormatRaw {
+		if _, err := exec.LookPath("qemu-img"); err != nil {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("qemu-img is required to export to %s", p.config.Format))
+		}
+	}
+	if p.config.S3 != nil && p.config.S3.Bucket == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("s3.bucket is required"))
+	}
+
+	// The server options are validated by the builder, the image is set for
+	// each snapshot when exporting
+	serverType := p.config.ServerType
+	for _, architectureServerType := range p.config.Architectures {
+		if serverType == "" {
+			serverType = architectureServerType
+		}
+	}
+	if _, err := p.builderConfig("snapshot", serverType); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	snapshotIDs, err := hcloudbuilder.SnapshotIDsFromArtifact(artifact)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	client := p.config.NewClient()
+
+	result := &Artifact{}
+	for _, snapshotID := range snapshotIDs {
+		snapshot, _, err := client.Image.GetByID(ctx, snapshotID)
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Could not fetch snapshot id=%d: %w", snapshotID, err)
+		}
+		if snapshot == nil {
+			return nil, false, false, fmt.Errorf("Could not find snapshot id=%d", snapshotID)
+		}
+
+		if err := p.export(ctx, ui, snapshot, result); err != nil {
+			return nil, false, false, err
+		}
+	}
+
+	return result, true, false, nil
+}
+
+// export exports the snapshot to a local file, and uploads it to the S3 bucket
+// if configured.
+func (p *PostProcessor) export(ctx context.Context, ui packersdk.Ui, snapshot *hcloud.Image, result *Artifact) error {
+	serverType := p.config.ServerType
+	if architectureServerType, ok := p.config.Architectures[string(snapshot.Architecture)]; ok {
+		serverType = architectureServerType
+	}
+	if serverType == "" {
+		return fmt.Errorf("No server type for the %s architecture of snapshot id=%d", snapshot.Architecture, snapshot.ID)
+	}
+
+	p.config.ctx.Data = &outputTemplateData{
+		SnapshotID:   snapshot.ID,
+		SnapshotName: snapshot.Description,
+		Architecture: string(snapshot.Architecture),
+		Format:       p.config.Format,
+	}
+	output, err := interpolate.Render(p.config.Output, &p.config.ctx)
+	if err != nil {
+		return fmt.Errorf("Could not render output: %w", err)
+	}
+	if slices.Contains(result.files, output) {
+		return fmt.Errorf("Output %q is used by more than one snapshot, use {{ .SnapshotID }} in the output", output)
+	}
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("Could not create output directory: %w", err)
+		}
+	}
+
+	builderConfig, err := p.builderConfig(fmt.Sprintf("%d", snapshot.ID), serverType)
+	if err != nil {
+		return err
+	}
+
+	// The disk is always read as raw, and converted locally afterwards
+	rawPath := output
+	if p.config.Format != FormatRaw {
+		rawPath = output + ".raw"
+		defer os.Remove(rawPath)
+	}
+
+	ui.Say(fmt.Sprintf("Exporting snapshot %d to %s...", snapshot.ID, output))
+	if err := hcloudbuilder.Export(ctx, ui, builderConfig, &stepReadDisk{Path: rawPath, Disk: disk}); err != nil {
+		return err
+	}
+
+	if p.config.Format != FormatRaw {
+		ui.Say(fmt.Sprintf("Converting disk image to %s...", p.config.Format))
+		cmd := exec.CommandContext(ctx, "qemu-img", "convert", "-f", "raw", "-O", p.config.Format, rawPath, output)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("Could not convert disk image: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	result.files = append(result.files, output)
+
+	if p.config.S3 != nil {
+		url, err := uploadToS3(ctx, ui, p.config.S3, output)
+		if err != nil {
+			return err
+		}
+		result.urls = append(result.urls, url)
+	}
+
+	return nil
+}
+
+// builderConfig returns the config of the builder, to create a server from the
+// snapshot booted in rescue mode.
+func (p *PostProcessor) builderConfig(image, serverType string) (*hcloudbuilder.Config, error) {
+	raw := map[string]interface{}{
+		"packer_build_name":    p.config.PackerBuildName,
+		"packer_debug":         p.config.PackerDebug,
+		"token":                p.config.HCloudToken,
+		"endpoint":             p.config.Endpoint,
+		"poll_interval":        p.config.PollInterval,
+		"location":             p.config.Location,
+		"locations":            p.config.Locations,
+		"server_type":          serverType,
+		"image":                image,
+		"networks":             p.config.Networks,
+		"public_ipv4":          p.config.PublicIPv4,
+		"public_ipv4_disabled": p.config.PublicIPv4Disabled,
+		"public_ipv6":          p.config.PublicIPv6,
+		"public_ipv6_disabled": p.config.PublicIPv6Disabled,
+		"rescue":               "linux64",
+		"communicator":         "ssh",
+		"ssh_username":         "root",
+	}
+
+	c := &hcloudbuilder.Config{}
+	if _, err := c.Prepare(raw); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
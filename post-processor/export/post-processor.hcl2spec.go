@@ -0,0 +1,110 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package hcloudexport
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	PackerBuildName     *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType   *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion   *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug         *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce         *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError       *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars      map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HCloudToken         *string           `mapstructure:"token" cty:"token" hcl:"token"`
+	Endpoint            *string           `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	PollInterval        *string           `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
+	Location            *string           `mapstructure:"location" cty:"location" hcl:"location"`
+	Locations           []string          `mapstructure:"locations" cty:"locations" hcl:"locations"`
+	ServerType          *string           `mapstructure:"server_type" cty:"server_type" hcl:"server_type"`
+	Architectures       map[string]string `mapstructure:"architectures" cty:"architectures" hcl:"architectures"`
+	Networks            []int64           `mapstructure:"networks" cty:"networks" hcl:"networks"`
+	PublicIPv4          *string           `mapstructure:"public_ipv4" cty:"public_ipv4" hcl:"public_ipv4"`
+	PublicIPv4Disabled  *bool             `mapstructure:"public_ipv4_disabled" cty:"public_ipv4_disabled" hcl:"public_ipv4_disabled"`
+	PublicIPv6          *string           `mapstructure:"public_ipv6" cty:"public_ipv6" hcl:"public_ipv6"`
+	PublicIPv6Disabled  *bool             `mapstructure:"public_ipv6_disabled" cty:"public_ipv6_disabled" hcl:"public_ipv6_disabled"`
+	Format              *string           `mapstructure:"format" cty:"format" hcl:"format"`
+	Output              *string           `mapstructure:"output" cty:"output" hcl:"output"`
+	S3                  *FlatS3Config     `mapstructure:"s3" cty:"s3" hcl:"s3"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":        &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":               &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":               &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":            &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":      &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables": &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"token":                      &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"endpoint":                   &hcldec.AttrSpec{Name: "endpoint", Type: cty.String, Required: false},
+		"poll_interval":              &hcldec.AttrSpec{Name: "poll_interval", Type: cty.String, Required: false},
+		"location":                   &hcldec.AttrSpec{Name: "location", Type: cty.String, Required: false},
+		"locations":                  &hcldec.AttrSpec{Name: "locations", Type: cty.List(cty.String), Required: false},
+		"server_type":                &hcldec.AttrSpec{Name: "server_type", Type: cty.String, Required: false},
+		"architectures":              &hcldec.AttrSpec{Name: "architectures", Type: cty.Map(cty.String), Required: false},
+		"networks":                   &hcldec.AttrSpec{Name: "networks", Type: cty.List(cty.Number), Required: false},
+		"public_ipv4":                &hcldec.AttrSpec{Name: "public_ipv4", Type: cty.String, Required: false},
+		"public_ipv4_disabled":       &hcldec.AttrSpec{Name: "public_ipv4_disabled", Type: cty.Bool, Required: false},
+		"public_ipv6":                &hcldec.AttrSpec{Name: "public_ipv6", Type: cty.String, Required: false},
+		"public_ipv6_disabled":       &hcldec.AttrSpec{Name: "public_ipv6_disabled", Type: cty.Bool, Required: false},
+		"format":                     &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
+		"output":                     &hcldec.AttrSpec{Name: "output", Type: cty.String, Required: false},
+		"s3":                         &hcldec.BlockSpec{TypeName: "s3", Nested: hcldec.ObjectSpec((*FlatS3Config)(nil).HCL2Spec())},
+	}
+	return s
+}
+
+// FlatS3Config is an auto-generated flat version of S3Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatS3Config struct {
+	Bucket         *string `mapstructure:"bucket" cty:"bucket" hcl:"bucket"`
+	Prefix         *string `mapstructure:"prefix" cty:"prefix" hcl:"prefix"`
+	Endpoint       *string `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	Region         *string `mapstructure:"region" cty:"region" hcl:"region"`
+	AccessKey      *string `mapstructure:"access_key" cty:"access_key" hcl:"access_key"`
+	SecretKey      *string `mapstructure:"secret_key" cty:"secret_key" hcl:"secret_key"`
+	ForcePathStyle *bool   `mapstructure:"force_path_style" cty:"force_path_style" hcl:"force_path_style"`
+}
+
+// FlatMapstructure returns a new FlatS3Config.
+// FlatS3Config is an auto-generated flat version of S3Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*S3Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatS3Config)
+}
+
+// HCL2Spec returns the hcl spec of a S3Config.
+// This spec is used by HCL to read the fields of S3Config.
+// The decoded values from this spec will then be applied to a FlatS3Config.
+func (*FlatS3Config) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"bucket":           &hcldec.AttrSpec{Name: "bucket", Type: cty.String, Required: false},
+		"prefix":           &hcldec.AttrSpec{Name: "prefix", Type: cty.String, Required: false},
+		"endpoint":         &hcldec.AttrSpec{Name: "endpoint", Type: cty.String, Required: false},
+		"region":           &hcldec.AttrSpec{Name: "region", Type: cty.String, Required: false},
+		"access_key":       &hcldec.AttrSpec{Name: "access_key", Type: cty.String, Required: false},
+		"secret_key":       &hcldec.AttrSpec{Name: "secret_key", Type: cty.String, Required: false},
+		"force_path_style": &hcldec.AttrSpec{Name: "force_path_style", Type: cty.Bool, Required: false},
+	}
+	return s
+}
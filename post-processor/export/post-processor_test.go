@@ -0,0 +1,211 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudexport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+	"github.com/hetznercloud/packer-plugin-hcloud/internal/testutil"
+)
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestArtifact_ImplementsArtifact(t *testing.T) {
+	var _ packersdk.Artifact = new(Artifact)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "minimal",
+			raw:  map[string]interface{}{"location": "fsn1", "server_type": "cpx22"},
+		},
+		{
+			name: "architectures",
+			raw: map[string]interface{}{"location": "fsn1", "architectures": map[string]string{
+				"x86": "cpx22",
+				"arm": "cax11",
+			}},
+		},
+		{
+			name:    "missing location",
+			raw:     map[string]interface{}{"server_type": "cpx22"},
+			wantErr: "location or locations is required",
+		},
+		{
+			name:    "invalid format",
+			raw:     map[string]interface{}{"location": "fsn1", "server_type": "cpx22", "format": "vdi"},
+			wantErr: "format must be one of raw, qcow2, vmdk",
+		},
+		{
+			name:    "invalid output",
+			raw:     map[string]interface{}{"location": "fsn1", "server_type": "cpx22", "output": "{{ .SnapshotID"},
+			wantErr: "invalid 'output'",
+		},
+		{
+			name:    "missing bucket",
+			raw:     map[string]interface{}{"location": "fsn1", "server_type": "cpx22", "s3": map[string]interface{}{"prefix": "images/"}},
+			wantErr: "s3.bucket is required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			p := &PostProcessor{}
+			err := p.Configure(tc.raw)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, FormatRaw, p.config.Format)
+				assert.Equal(t, defaultOutput, p.config.Output)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostProcess_unsupportedArtifact(t *testing.T) {
+	p := &PostProcessor{}
+	artifact := &packersdk.MockArtifact{BuilderIdValue: "packer.file"}
+
+	_, _, _, err := p.PostProcess(context.Background(), &packersdk.MockUi{}, artifact)
+	assert.ErrorContains(t, err, "unsupported artifact type")
+}
+
+func TestStepReadDisk(t *testing.T) {
+	for _, name := range []string{"bash", "gzip"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is required", name)
+		}
+	}
+
+	dir := t.TempDir()
+
+	// A disk with data at the start and the end, and empty blocks in between
+	content := make([]byte, 8<<20)
+	copy(content, bytes.Repeat([]byte("packer"), 4096))
+	copy(content[len(content)-6:], "packer")
+
+	disk := filepath.Join(dir, "sda")
+	require.NoError(t, os.WriteFile(disk, content, 0o600))
+	output := filepath.Join(dir, "disk.raw")
+
+	state := new(multistep.BasicStateBag)
+	state.Put(hcloudbuilder.StateUI, &packersdk.MockUi{})
+	state.Put("communicator", testutil.NewLocalCommunicator(""))
+
+	step := &stepReadDisk{Path: output, Disk: disk}
+	action := step.Run(context.Background(), state)
+	if err, ok := state.GetOk(hcloudbuilder.StateError); ok {
+		t.Fatal(err)
+	}
+	assert.Equal(t, multistep.ActionContinue, action)
+
+	exported, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Equal(t, content, exported)
+}
+
+func TestStepReadDisk_failure(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is required")
+	}
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "disk.raw")
+
+	state := new(multistep.BasicStateBag)
+	state.Put(hcloudbuilder.StateUI, &packersdk.MockUi{})
+	state.Put("communicator", testutil.NewLocalCommunicator(""))
+
+	step := &stepReadDisk{Path: output, Disk: filepath.Join(dir, "missing")}
+	action := step.Run(context.Background(), state)
+	assert.Equal(t, multistep.ActionHalt, action)
+	assert.NoFileExists(t, output)
+}
+
+func TestUploadToS3(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		objects = map[string][]byte{}
+	)
+
+	// Stand-in for an S3 compatible endpoint, storing the uploaded objects
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		mu.Lock()
+		objects[r.URL.Path] = body
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"dummy"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "disk.qcow2")
+	require.NoError(t, os.WriteFile(path, []byte("packer"), 0o600))
+
+	url, err := uploadToS3(context.Background(), &packersdk.MockUi{}, &S3Config{
+		Bucket:         "images",
+		Prefix:         "hcloud/",
+		Endpoint:       server.URL,
+		Region:         "us-east-1",
+		AccessKey:      "access",
+		SecretKey:      "secret",
+		ForcePathStyle: true,
+	}, path)
+	require.NoError(t, err)
+	assert.Equal(t, "s3://images/hcloud/disk.qcow2", url)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string][]byte{"/images/hcloud/disk.qcow2": []byte("packer")}, objects)
+}
+
+func TestArtifact(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "disk.raw")
+	require.NoError(t, os.WriteFile(path, []byte("packer"), 0o600))
+
+	artifact := &Artifact{files: []string{path}, urls: []string{"s3://images/disk.raw"}}
+	assert.Equal(t, []string{path}, artifact.Files())
+	assert.Equal(t, path, artifact.Id())
+	assert.Equal(t, []string{"s3://images/disk.raw"}, artifact.State("s3_urls"))
+	assert.Contains(t, artifact.String(), "uploaded to: s3://images/disk.raw")
+
+	require.NoError(t, artifact.Destroy())
+	assert.NoFileExists(t, path)
+}
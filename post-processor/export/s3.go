@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudexport
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+// uploadToS3 uploads the file to the S3 bucket, and returns the URL of the
+// object.
+func uploadToS3(ctx context.Context, ui packersdk.Ui, c *S3Config, path string) (string, error) {
+	opts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(c.Region),
+	}
+	if c.AccessKey != "" || c.SecretKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(c.AccessKey, c.SecretKey, "")))
+	}
+
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("Could not load S3 config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		o.UsePathStyle = c.ForcePathStyle
+		if c.Endpoint != "" {
+			o.BaseEndpoint = aws.String(c.Endpoint)
+		}
+	})
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("Could not open output: %w", err)
+	}
+	defer file.Close()
+
+	key := c.Prefix + filepath.Base(path)
+	url := fmt.Sprintf("s3://%s/%s", c.Bucket, key)
+
+	ui.Say(fmt.Sprintf("Uploading %s to %s...", path, url))
+	_, err = transfermanager.New(client).UploadObject(ctx, &transfermanager.UploadObjectInput{
+		Bucket: aws.String(c.Bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	if err != nil {
+		return "", fmt.Errorf("Could not upload to %s: %w", url, err)
+	}
+
+	return url, nil
+}
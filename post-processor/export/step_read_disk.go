@@ -0,0 +1,130 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudexport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+const readDiskScriptPath = "/tmp/packer-export.sh"
+
+// stepReadDisk streams the disk of the server booted in rescue mode to a local
+// raw file. The data is compressed during the transfer, and the empty blocks
+// are not written to the local file.
+type stepReadDisk struct {
+	Path string
+	Disk string
+}
+
+func (s *stepReadDisk) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get(hcloudbuilder.StateUI).(packersdk.Ui)
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	if err := s.readDisk(ctx, ui, comm); err != nil {
+		os.Remove(s.Path)
+		state.Put(hcloudbuilder.StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepReadDisk) readDisk(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator) error {
+	script := fmt.Sprintf("set -euo pipefail\ngzip -1 -c <%s\n", s.Disk)
+	if err := comm.Upload(readDiskScriptPath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Could not upload script: %w", err)
+	}
+
+	file, err := os.Create(s.Path)
+	if err != nil {
+		return fmt.Errorf("Could not create output: %w", err)
+	}
+	defer file.Close()
+
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- writeSparse(file, reader)
+		// Unblock the remote command if the local write failed
+		reader.Close()
+	}()
+
+	ui.Say(fmt.Sprintf("Reading disk %s...", s.Disk))
+
+	// The disk is not streamed through the UI, the output is only written to
+	// the local file
+	stderr := new(bytes.Buffer)
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("bash %s", readDiskScriptPath),
+		Stdout:  writer,
+		Stderr:  stderr,
+	}
+	if err := comm.Start(ctx, cmd); err != nil {
+		writer.Close()
+		<-done
+		return fmt.Errorf("Could not read disk: %w", err)
+	}
+	cmd.Wait()
+	writer.Close()
+
+	if err := <-done; err != nil {
+		return fmt.Errorf("Could not write output: %w", err)
+	}
+	if cmd.ExitStatus() != 0 {
+		return fmt.Errorf("Could not read disk: script exited with status %d: %s",
+			cmd.ExitStatus(), strings.TrimSpace(stderr.String()))
+	}
+
+	return file.Close()
+}
+
+func (s *stepReadDisk) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
+
+// writeSparse decompresses the data to the file, skipping the blocks of zeros
+// to create a sparse file.
+func writeSparse(file *os.File, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1<<20)
+	zeros := make([]byte, len(buf))
+
+	// The gzip reader verifies the checksum of the stream at the end, a
+	// truncated stream is reported as an error
+	var offset int64
+	for {
+		n, err := gz.Read(buf)
+		if n > 0 {
+			if !bytes.Equal(buf[:n], zeros[:n]) {
+				if _, err := file.WriteAt(buf[:n], offset); err != nil {
+					return err
+				}
+			}
+			offset += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return file.Truncate(offset)
+}
@@ -0,0 +1,202 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package hcloudimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+const (
+	FormatRaw   = "raw"
+	FormatQCOW2 = "qcow2"
+	FormatXZ    = "xz"
+
+	defaultImage = "ubuntu-24.04"
+	defaultDisk  = "/dev/sda"
+)
+
+var (
+	formats = []string{FormatRaw, FormatQCOW2, FormatXZ}
+
+	diskRegexp = regexp.MustCompile(`^/dev/[a-z0-9/_-]+$`)
+)
+
+type Config struct {
+	common.PackerConfig        `mapstructure:",squash"`
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	Location           string   `mapstructure:"location"`
+	Locations          []string `mapstructure:"locations"`
+	ServerType         string   `mapstructure:"server_type"`
+	Image              string   `mapstructure:"image"`
+	Networks           []int64  `mapstructure:"networks"`
+	PublicIPv4         string   `mapstructure:"public_ipv4"`
+	PublicIPv4Disabled bool     `mapstructure:"public_ipv4_disabled"`
+	PublicIPv6         string   `mapstructure:"public_ipv6"`
+	PublicIPv6Disabled bool     `mapstructure:"public_ipv6_disabled"`
+
+	SnapshotName   string            `mapstructure:"snapshot_name"`
+	SnapshotLabels map[string]string `mapstructure:"snapshot_labels"`
+
+	Format string `mapstructure:"format"`
+	Disk   string `mapstructure:"disk"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config        Config
+	builderConfig hcloudbuilder.Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hcloud-import",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	// Defaults
+	if p.config.Image == "" {
+		p.config.Image = defaultImage
+	}
+	if p.config.Disk == "" {
+		p.config.Disk = defaultDisk
+	}
+
+	var errs *packersdk.MultiError
+	if p.config.Format != "" && !slices.Contains(formats, p.config.Format) {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("format must be one of %s", strings.Join(formats, ", ")))
+	}
+	if !diskRegexp.MatchString(p.config.Disk) {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("disk must be a device path, for example %q", defaultDisk))
+	}
+
+	// The server options are validated by the builder, which creates the
+	// temporary server and the snapshot
+	raw := map[string]interface{}{
+		"packer_build_name":    p.config.PackerBuildName,
+		"packer_debug":         p.config.PackerDebug,
+		"token":                p.config.HCloudToken,
+		"endpoint":             p.config.Endpoint,
+		"poll_interval":        p.config.PollInterval,
+		"location":             p.config.Location,
+		"locations":            p.config.Locations,
+		"server_type":          p.config.ServerType,
+		"image":                p.config.Image,
+		"networks":             p.config.Networks,
+		"public_ipv4":          p.config.PublicIPv4,
+		"public_ipv4_disabled": p.config.PublicIPv4Disabled,
+		"public_ipv6":          p.config.PublicIPv6,
+		"public_ipv6_disabled": p.config.PublicIPv6Disabled,
+		"snapshot_name":        p.config.SnapshotName,
+		"snapshot_labels":      p.config.SnapshotLabels,
+		"rescue":               "linux64",
+		"communicator":         "ssh",
+		"ssh_username":         "root",
+	}
+	if _, err := p.builderConfig.Prepare(raw); err != nil {
+		errs = packersdk.MultiErrorAppend(errs, err)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	path, format, err := p.imageFile(artifact)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	ui.Say(fmt.Sprintf("Importing %s image: %s", format, path))
+
+	result, err := hcloudbuilder.Import(ctx, ui, &p.builderConfig, &stepWriteImage{
+		Path:   path,
+		Format: format,
+		Disk:   p.config.Disk,
+	})
+	if err != nil {
+		return nil, false, false, err
+	}
+	if result == nil {
+		return nil, false, false, errors.New("no snapshot was created")
+	}
+
+	// The server image is only used to create the temporary server
+	result.StateData["source_image"] = filepath.Base(path)
+	delete(result.StateData, "source_image_id")
+
+	return result, false, false, nil
+}
+
+// imageFile returns the disk image file of the artifact, and its format.
+func (p *PostProcessor) imageFile(artifact packersdk.Artifact) (string, string, error) {
+	files := artifact.Files()
+
+	var candidates []string
+	for _, file := range files {
+		if formatFromPath(file) != "" {
+			candidates = append(candidates, file)
+		}
+	}
+	if len(candidates) == 0 && len(files) == 1 {
+		candidates = files
+	}
+
+	switch {
+	case len(candidates) == 0:
+		return "", "", fmt.Errorf("no disk image found in artifact from %q", artifact.BuilderId())
+	case len(candidates) > 1:
+		return "", "", fmt.Errorf("more than one disk image found in artifact from %q: %s",
+			artifact.BuilderId(), strings.Join(candidates, ", "))
+	}
+
+	path := candidates[0]
+	format := p.config.Format
+	if format == "" {
+		format = formatFromPath(path)
+	}
+	if format == "" {
+		return "", "", fmt.Errorf("could not detect the format of %q, please specify the format", path)
+	}
+	return path, format, nil
+}
+
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".raw", ".img":
+		return FormatRaw
+	case ".qcow2":
+		return FormatQCOW2
+	case ".xz":
+		return FormatXZ
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package hcloudimport
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	PackerBuildName     *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType   *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion   *string           `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug         *bool             `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce         *bool             `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError       *string           `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars      map[string]string `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars []string          `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HCloudToken         *string           `mapstructure:"token" cty:"token" hcl:"token"`
+	Endpoint            *string           `mapstructure:"endpoint" cty:"endpoint" hcl:"endpoint"`
+	PollInterval        *string           `mapstructure:"poll_interval" cty:"poll_interval" hcl:"poll_interval"`
+	Location            *string           `mapstructure:"location" cty:"location" hcl:"location"`
+	Locations           []string          `mapstructure:"locations" cty:"locations" hcl:"locations"`
+	ServerType          *string           `mapstructure:"server_type" cty:"server_type" hcl:"server_type"`
+	Image               *string           `mapstructure:"image" cty:"image" hcl:"image"`
+	Networks            []int64           `mapstructure:"networks" cty:"networks" hcl:"networks"`
+	PublicIPv4          *string           `mapstructure:"public_ipv4" cty:"public_ipv4" hcl:"public_ipv4"`
+	PublicIPv4Disabled  *bool             `mapstructure:"public_ipv4_disabled" cty:"public_ipv4_disabled" hcl:"public_ipv4_disabled"`
+	PublicIPv6          *string           `mapstructure:"public_ipv6" cty:"public_ipv6" hcl:"public_ipv6"`
+	PublicIPv6Disabled  *bool             `mapstructure:"public_ipv6_disabled" cty:"public_ipv6_disabled" hcl:"public_ipv6_disabled"`
+	SnapshotName        *string           `mapstructure:"snapshot_name" cty:"snapshot_name" hcl:"snapshot_name"`
+	SnapshotLabels      map[string]string `mapstructure:"snapshot_labels" cty:"snapshot_labels" hcl:"snapshot_labels"`
+	Format              *string           `mapstructure:"format" cty:"format" hcl:"format"`
+	Disk                *string           `mapstructure:"disk" cty:"disk" hcl:"disk"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
+		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
+		"packer_core_version":        &hcldec.AttrSpec{Name: "packer_core_version", Type: cty.String, Required: false},
+		"packer_debug":               &hcldec.AttrSpec{Name: "packer_debug", Type: cty.Bool, Required: false},
+		"packer_force":               &hcldec.AttrSpec{Name: "packer_force", Type: cty.Bool, Required: false},
+		"packer_on_error":            &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
+		"packer_user_variables":      &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
+		"packer_sensitive_variables": &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"token":                      &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"endpoint":                   &hcldec.AttrSpec{Name: "endpoint", Type: cty.String, Required: false},
+		"poll_interval":              &hcldec.AttrSpec{Name: "poll_interval", Type: cty.String, Required: false},
+		"location":                   &hcldec.AttrSpec{Name: "location", Type: cty.String, Required: false},
+		"locations":                  &hcldec.AttrSpec{Name: "locations", Type: cty.List(cty.String), Required: false},
+		"server_type":                &hcldec.AttrSpec{Name: "server_type", Type: cty.String, Required: false},
+		"image":                      &hcldec.AttrSpec{Name: "image", Type: cty.String, Required: false},
+		"networks":                   &hcldec.AttrSpec{Name: "networks", Type: cty.List(cty.Number), Required: false},
+		"public_ipv4":                &hcldec.AttrSpec{Name: "public_ipv4", Type: cty.String, Required: false},
+		"public_ipv4_disabled":       &hcldec.AttrSpec{Name: "public_ipv4_disabled", Type: cty.Bool, Required: false},
+		"public_ipv6":                &hcldec.AttrSpec{Name: "public_ipv6", Type: cty.String, Required: false},
+		"public_ipv6_disabled":       &hcldec.AttrSpec{Name: "public_ipv6_disabled", Type: cty.Bool, Required: false},
+		"snapshot_name":              &hcldec.AttrSpec{Name: "snapshot_name", Type: cty.String, Required: false},
+		"snapshot_labels":            &hcldec.AttrSpec{Name: "snapshot_labels", Type: cty.Map(cty.String), Required: false},
+		"format":                     &hcldec.AttrSpec{Name: "format", Type: cty.String, Required: false},
+		"disk":                       &hcldec.AttrSpec{Name: "disk", Type: cty.String, Required: false},
+	}
+	return s
+}
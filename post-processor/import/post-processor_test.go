@@ -0,0 +1,206 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudimport
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+	"github.com/hetznercloud/packer-plugin-hcloud/internal/testutil"
+)
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "minimal",
+			raw:  map[string]interface{}{"location": "fsn1", "server_type": "cpx22"},
+		},
+		{
+			name:    "missing location",
+			raw:     map[string]interface{}{"server_type": "cpx22"},
+			wantErr: "location or locations is required",
+		},
+		{
+			name:    "invalid format",
+			raw:     map[string]interface{}{"location": "fsn1", "server_type": "cpx22", "format": "vmdk"},
+			wantErr: "format must be one of raw, qcow2, xz",
+		},
+		{
+			name:    "invalid disk",
+			raw:     map[string]interface{}{"location": "fsn1", "server_type": "cpx22", "disk": "/dev/sda; reboot"},
+			wantErr: "disk must be a device path",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			p := &PostProcessor{}
+			err := p.Configure(tc.raw)
+			if tc.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, "linux64", p.builderConfig.RescueMode)
+				assert.Equal(t, "root", p.builderConfig.Comm.SSHUsername)
+				assert.Equal(t, defaultImage, p.builderConfig.Image)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestImageFile(t *testing.T) {
+	testCases := []struct {
+		name       string
+		format     string
+		files      []string
+		wantPath   string
+		wantFormat string
+		wantErr    string
+	}{
+		{
+			name:       "qcow2",
+			files:      []string{"output/disk.qcow2", "output/efivars.fd"},
+			wantPath:   "output/disk.qcow2",
+			wantFormat: FormatQCOW2,
+		},
+		{
+			name:       "single file with format",
+			format:     FormatQCOW2,
+			files:      []string{"output/packer-debian"},
+			wantPath:   "output/packer-debian",
+			wantFormat: FormatQCOW2,
+		},
+		{
+			name:    "single file without format",
+			files:   []string{"output/packer-debian"},
+			wantErr: "could not detect the format",
+		},
+		{
+			name:    "multiple images",
+			files:   []string{"output/disk.raw", "output/disk.raw.xz"},
+			wantErr: "more than one disk image found",
+		},
+		{
+			name:    "no files",
+			wantErr: "no disk image found",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &PostProcessor{config: Config{Format: tc.format}}
+			artifact := &packersdk.MockArtifact{BuilderIdValue: "transcend.qemu", FilesValue: tc.files}
+
+			path, format, err := p.imageFile(artifact)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantPath, path)
+			assert.Equal(t, tc.wantFormat, format)
+		})
+	}
+}
+
+func TestStepWriteImage(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is required")
+	}
+
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("packer"), 4096)
+
+	image := filepath.Join(dir, "disk.raw")
+	require.NoError(t, os.WriteFile(image, content, 0o600))
+	disk := filepath.Join(dir, "sda")
+	require.NoError(t, os.WriteFile(disk, nil, 0o600))
+
+	state := new(multistep.BasicStateBag)
+	state.Put(hcloudbuilder.StateUI, &packersdk.MockUi{})
+	state.Put("communicator", testutil.NewLocalCommunicator(""))
+
+	step := &stepWriteImage{Path: image, Format: FormatRaw, Disk: disk}
+	action := step.Run(context.Background(), state)
+	if err, ok := state.GetOk(hcloudbuilder.StateError); ok {
+		t.Fatal(err)
+	}
+	assert.Equal(t, multistep.ActionContinue, action)
+
+	written, err := os.ReadFile(disk)
+	require.NoError(t, err)
+	assert.Equal(t, content, written)
+}
+
+func TestStepWriteImageQCOW2(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash is required")
+	}
+
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("packer"), 4096)
+
+	image := filepath.Join(dir, "disk.qcow2")
+	require.NoError(t, os.WriteFile(image, content, 0o600))
+	disk := filepath.Join(dir, "sda")
+	require.NoError(t, os.WriteFile(disk, nil, 0o600))
+
+	// The disk is a file, the tools of the rescue system are replaced by stubs
+	bin := filepath.Join(dir, "bin")
+	require.NoError(t, os.Mkdir(bin, 0o700))
+	stubs := map[string]string{
+		"blockdev":   "echo 8388608",
+		"qemu-img":   `if [[ $1 == info ]]; then printf '{\n    "virtual-size": 1048576,\n}\n'; else echo "$@" >` + filepath.Join(dir, "convert") + `; fi`,
+		"blkdiscard": `echo "$@" >` + filepath.Join(dir, "blkdiscard"),
+	}
+	for name, stub := range stubs {
+		require.NoError(t, os.WriteFile(filepath.Join(bin, name), []byte("#!/bin/bash\n"+stub+"\n"), 0o700))
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	state := new(multistep.BasicStateBag)
+	state.Put(hcloudbuilder.StateUI, &packersdk.MockUi{})
+	state.Put("communicator", testutil.NewLocalCommunicator(""))
+
+	step := &stepWriteImage{Path: image, Format: FormatQCOW2, Disk: disk}
+	action := step.Run(context.Background(), state)
+	if err, ok := state.GetOk(hcloudbuilder.StateError); ok {
+		t.Fatal(err)
+	}
+	assert.Equal(t, multistep.ActionContinue, action)
+
+	// The image is staged in the last MiB of the disk
+	written, err := os.ReadFile(disk)
+	require.NoError(t, err)
+	assert.Equal(t, content, written[7340032:])
+
+	convert, err := os.ReadFile(filepath.Join(dir, "convert"))
+	require.NoError(t, err)
+	assert.Contains(t, string(convert), `"offset": 7340032, "size": 24576`)
+	assert.Contains(t, string(convert), "-n -O raw")
+
+	blkdiscard, err := os.ReadFile(filepath.Join(dir, "blkdiscard"))
+	require.NoError(t, err)
+	assert.Equal(t, "-z -o 7340032 "+disk+"\n", string(blkdiscard))
+}
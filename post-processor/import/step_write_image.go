@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudimport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+const writeImageScriptPath = "/tmp/packer-import.sh"
+
+// stepWriteImage streams the disk image to the disk of the server booted in
+// rescue mode. The checksum of the data received by the server is compared
+// with the checksum of the local file. qcow2 images are staged at the end of
+// the disk, which must be large enough for both the image and its content.
+type stepWriteImage struct {
+	Path   string
+	Format string
+	Disk   string
+}
+
+func (s *stepWriteImage) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui := state.Get(hcloudbuilder.StateUI).(packersdk.Ui)
+	comm := state.Get("communicator").(packersdk.Communicator)
+
+	if err := s.writeImage(ctx, ui, comm); err != nil {
+		state.Put(hcloudbuilder.StateError, err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+	return multistep.ActionContinue
+}
+
+func (s *stepWriteImage) writeImage(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator) error {
+	file, err := os.Open(s.Path)
+	if err != nil {
+		return fmt.Errorf("Could not open image: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("Could not open image: %w", err)
+	}
+
+	script := hcloudbuilder.WriteDiskImageScript("cat", s.Format, "sha256", s.Disk, info.Size())
+	if err := comm.Upload(writeImageScriptPath, strings.NewReader(script), nil); err != nil {
+		return fmt.Errorf("Could not upload script: %w", err)
+	}
+
+	hash := sha256.New()
+	stdout := new(bytes.Buffer)
+
+	ui.Say(fmt.Sprintf("Writing image to %s...", s.Disk))
+	cmd := &packersdk.RemoteCmd{
+		Command: fmt.Sprintf("bash %s", writeImageScriptPath),
+		Stdin:   io.TeeReader(file, hash),
+		Stdout:  stdout,
+	}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		return fmt.Errorf("Could not write image: %w", err)
+	}
+	if cmd.ExitStatus() != 0 {
+		return fmt.Errorf("Could not write image: script exited with status %d", cmd.ExitStatus())
+	}
+
+	localChecksum := hex.EncodeToString(hash.Sum(nil))
+	remoteChecksum := lastLine(stdout.String())
+	if localChecksum != remoteChecksum {
+		return fmt.Errorf("Image checksum mismatch: expected %s, got %s", localChecksum, remoteChecksum)
+	}
+	ui.Say(fmt.Sprintf("Image checksum verified: sha256:%s", localChecksum))
+
+	return nil
+}
+
+func (s *stepWriteImage) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,205 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package snapshotlifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+type Config struct {
+	common.PackerConfig        `mapstructure:",squash"`
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	PromoteLabels    map[string]string `mapstructure:"promote_labels"`
+	DeleteProtection *bool             `mapstructure:"delete_protection"`
+	Description      string            `mapstructure:"description"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hcloud-snapshot-lifecycle",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	if es := p.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	for key, value := range p.config.PromoteLabels {
+		if key == "" || value == "" {
+			errs = packersdk.MultiErrorAppend(
+				errs, fmt.Errorf("promote_labels: key and value must not be empty (%q=%q)", key, value))
+		}
+	}
+	if len(p.config.PromoteLabels) == 0 && p.config.DeleteProtection == nil && p.config.Description == "" {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("one of promote_labels, delete_protection or description is required"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	snapshotIDs, err := hcloudbuilder.SnapshotIDsFromArtifact(artifact)
+	if err != nil {
+		return artifact, true, false, err
+	}
+
+	client := p.config.NewClient()
+
+	snapshots := make([]*hcloud.Image, 0, len(snapshotIDs))
+	for _, snapshotID := range snapshotIDs {
+		snapshot, _, err := client.Image.GetByID(ctx, snapshotID)
+		if err != nil {
+			return artifact, true, false, fmt.Errorf("Could not fetch snapshot id=%d: %w", snapshotID, err)
+		}
+		if snapshot == nil {
+			return artifact, true, false, fmt.Errorf("Could not find snapshot id=%d", snapshotID)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	// The labels are added to the new snapshots before being removed from the
+	// previous holders, a label selector always matches at least one snapshot.
+	for _, snapshot := range snapshots {
+		if err := p.updateSnapshot(ctx, ui, client, snapshot); err != nil {
+			return artifact, true, false, err
+		}
+	}
+
+	if len(p.config.PromoteLabels) > 0 {
+		if err := p.demotePreviousSnapshots(ctx, ui, client, snapshots); err != nil {
+			return artifact, true, false, err
+		}
+	}
+
+	if p.config.DeleteProtection != nil {
+		for _, snapshot := range snapshots {
+			if err := changeProtection(ctx, ui, client, snapshot, *p.config.DeleteProtection); err != nil {
+				return artifact, true, false, err
+			}
+		}
+	}
+
+	return artifact, true, false, nil
+}
+
+// updateSnapshot adds the promoted labels to the snapshot and updates its
+// description.
+func (p *PostProcessor) updateSnapshot(ctx context.Context, ui packersdk.Ui, client *hcloud.Client, snapshot *hcloud.Image) error {
+	if len(p.config.PromoteLabels) == 0 && p.config.Description == "" {
+		return nil
+	}
+
+	opts := hcloud.ImageUpdateOpts{}
+	if len(p.config.PromoteLabels) > 0 {
+		opts.Labels = maps.Clone(snapshot.Labels)
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string, len(p.config.PromoteLabels))
+		}
+		maps.Copy(opts.Labels, p.config.PromoteLabels)
+	}
+	if p.config.Description != "" {
+		opts.Description = hcloud.Ptr(p.config.Description)
+	}
+
+	ui.Say(fmt.Sprintf("Updating snapshot: %d", snapshot.ID))
+	if _, _, err := client.Image.Update(ctx, snapshot, opts); err != nil {
+		return fmt.Errorf("Could not update snapshot id=%d: %w", snapshot.ID, err)
+	}
+	return nil
+}
+
+// demotePreviousSnapshots removes the promoted labels from the snapshots that
+// previously held them. Only the snapshots with the same architecture as one
+// of the new snapshots are demoted.
+func (p *PostProcessor) demotePreviousSnapshots(ctx context.Context, ui packersdk.Ui, client *hcloud.Client, snapshots []*hcloud.Image) error {
+	var snapshotIDs []int64
+	var architectures []hcloud.Architecture
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, snapshot.ID)
+		architectures = append(architectures, snapshot.Architecture)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(p.config.PromoteLabels)) {
+		selector := fmt.Sprintf("%s=%s", key, p.config.PromoteLabels[key])
+
+		previous, err := client.Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+			ListOpts:     hcloud.ListOpts{LabelSelector: selector},
+			Type:         []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+			Architecture: architectures,
+		})
+		if err != nil {
+			return fmt.Errorf("Could not fetch snapshots with selector %q: %w", selector, err)
+		}
+
+		for _, snapshot := range previous {
+			if slices.Contains(snapshotIDs, snapshot.ID) {
+				continue
+			}
+
+			labels := maps.Clone(snapshot.Labels)
+			delete(labels, key)
+
+			ui.Say(fmt.Sprintf("Removing label %q from previous snapshot: %d", selector, snapshot.ID))
+			if _, _, err := client.Image.Update(ctx, snapshot, hcloud.ImageUpdateOpts{Labels: labels}); err != nil {
+				return fmt.Errorf("Could not update snapshot id=%d: %w", snapshot.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func changeProtection(ctx context.Context, ui packersdk.Ui, client *hcloud.Client, snapshot *hcloud.Image, protection bool) error {
+	if snapshot.Protection.Delete == protection {
+		return nil
+	}
+
+	if protection {
+		ui.Say(fmt.Sprintf("Enabling delete protection of snapshot: %d", snapshot.ID))
+	} else {
+		ui.Say(fmt.Sprintf("Disabling delete protection of snapshot: %d", snapshot.ID))
+	}
+	action, _, err := client.Image.ChangeProtection(ctx, snapshot, hcloud.ImageChangeProtectionOpts{
+		Delete: hcloud.Ptr(protection),
+	})
+	if err == nil {
+		err = client.Action.WaitFor(ctx, action)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not change protection of snapshot id=%d: %w", snapshot.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package snapshotlifecycle
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "promote_labels",
+			raw:  map[string]interface{}{"promote_labels": map[string]string{"channel": "stable"}},
+		},
+		{
+			name: "delete_protection",
+			raw:  map[string]interface{}{"delete_protection": false},
+		},
+		{
+			name:    "empty label value",
+			raw:     map[string]interface{}{"promote_labels": map[string]string{"channel": ""}},
+			wantErr: "promote_labels: key and value must not be empty",
+		},
+		{
+			name:    "missing action",
+			raw:     map[string]interface{}{},
+			wantErr: "one of promote_labels, delete_protection or description is required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			p := &PostProcessor{}
+			err := p.Configure(tc.raw)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func decodeImageUpdateRequest(t *testing.T, req *http.Request) *schema.ImageUpdateRequest {
+	t.Helper()
+
+	payload := &schema.ImageUpdateRequest{}
+	require.NoError(t, json.NewDecoder(req.Body).Decode(payload))
+	return payload
+}
+
+func TestPostProcess(t *testing.T) {
+	testCases := []struct {
+		name         string
+		raw          map[string]interface{}
+		artifactID   string
+		wantRequests []mockutil.Request
+		wantErr      string
+	}{
+		{
+			name: "promote",
+			raw: map[string]interface{}{
+				"promote_labels":    map[string]string{"channel": "stable"},
+				"delete_protection": true,
+				"description":       "app-stable",
+			},
+			artifactID: "16",
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images/16",
+					Status: 200,
+					JSONRaw: `{
+						"image": { "id": 16, "type": "snapshot", "architecture": "x86", "labels": { "app": "web" }, "protection": { "delete": false }}
+					}`,
+				},
+				{Method: "PUT", Path: "/images/16",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := decodeImageUpdateRequest(t, req)
+						assert.Equal(t, "app-stable", *payload.Description)
+						assert.Equal(t, map[string]string{"app": "web", "channel": "stable"}, *payload.Labels)
+					},
+					Status:  200,
+					JSONRaw: `{ "image": { "id": 16 }}`,
+				},
+				{Method: "GET", Path: "/images?architecture=x86&label_selector=channel%3Dstable&page=1&per_page=50&type=snapshot",
+					Status: 200,
+					JSONRaw: `{
+						"images": [
+							{ "id": 16, "type": "snapshot", "architecture": "x86", "labels": { "app": "web", "channel": "stable" }},
+							{ "id": 12, "type": "snapshot", "architecture": "x86", "labels": { "app": "web", "channel": "stable" }}
+						],
+						"meta": { "pagination": { "page": 1 }}
+					}`,
+				},
+				{Method: "PUT", Path: "/images/12",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := decodeImageUpdateRequest(t, req)
+						assert.Nil(t, payload.Description)
+						assert.Equal(t, map[string]string{"app": "web"}, *payload.Labels)
+					},
+					Status:  200,
+					JSONRaw: `{ "image": { "id": 12 }}`,
+				},
+				{Method: "POST", Path: "/images/16/actions/change_protection",
+					Status:  201,
+					JSONRaw: `{ "action": { "id": 4, "status": "success" }}`,
+				},
+			},
+		},
+		{
+			name:       "protection multiple architectures",
+			raw:        map[string]interface{}{"delete_protection": true},
+			artifactID: "arm:15,x86:16",
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images/15",
+					Status:  200,
+					JSONRaw: `{ "image": { "id": 15, "type": "snapshot", "architecture": "arm", "protection": { "delete": false }}}`,
+				},
+				{Method: "GET", Path: "/images/16",
+					Status:  200,
+					JSONRaw: `{ "image": { "id": 16, "type": "snapshot", "architecture": "x86", "protection": { "delete": true }}}`,
+				},
+				{Method: "POST", Path: "/images/15/actions/change_protection",
+					Status:  201,
+					JSONRaw: `{ "action": { "id": 4, "status": "success" }}`,
+				},
+			},
+		},
+		{
+			name:       "snapshot not found",
+			raw:        map[string]interface{}{"description": "app-stable"},
+			artifactID: "16",
+			wantRequests: []mockutil.Request{
+				{Method: "GET", Path: "/images/16",
+					Status:  404,
+					JSONRaw: `{ "error": { "code": "not_found", "message": "image not found" }}`,
+				},
+			},
+			wantErr: "Could not find snapshot id=16",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, tc.wantRequests))
+			defer server.Close()
+
+			tc.raw["token"] = "dummy"
+			tc.raw["endpoint"] = server.URL
+
+			p := &PostProcessor{}
+			require.NoError(t, p.Configure(tc.raw))
+
+			artifact := &packersdk.MockArtifact{BuilderIdValue: hcloudbuilder.BuilderId, IdValue: tc.artifactID}
+
+			result, keep, forceOverride, err := p.PostProcess(context.Background(), &packersdk.MockUi{}, artifact)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, artifact, result)
+			assert.True(t, keep)
+			assert.False(t, forceOverride)
+		})
+	}
+}
+
+func TestPostProcess_unsupportedArtifact(t *testing.T) {
+	p := &PostProcessor{}
+	require.NoError(t, p.Configure(map[string]interface{}{"token": "dummy", "description": "foo"}))
+
+	artifact := &packersdk.MockArtifact{BuilderIdValue: "packer.file"}
+
+	_, _, _, err := p.PostProcess(context.Background(), &packersdk.MockUi{}, artifact)
+	assert.ErrorContains(t, err, "unsupported artifact type")
+}
@@ -0,0 +1,190 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package snapshotretention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+// ExpiresAtLabel is the label used to set the expiration date of a snapshot.
+const ExpiresAtLabel = "expires_at"
+
+type Config struct {
+	common.PackerConfig        `mapstructure:",squash"`
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	WithSelector   []string      `mapstructure:"with_selector"`
+	KeepLatest     int           `mapstructure:"keep_latest"`
+	OlderThan      time.Duration `mapstructure:"older_than"`
+	HonorExpiresAt bool          `mapstructure:"honor_expires_at"`
+	DryRun         bool          `mapstructure:"dry_run"`
+
+	ctx interpolate.Context
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hcloud-snapshot-retention",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packersdk.MultiError
+	if es := p.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if len(p.config.WithSelector) == 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("with_selector is required"))
+	}
+	if p.config.KeepLatest < 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("keep_latest must be a positive number"))
+	}
+	if p.config.OlderThan < 0 {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("older_than must be a positive duration"))
+	}
+	if p.config.KeepLatest == 0 && p.config.OlderThan == 0 && !p.config.HonorExpiresAt {
+		errs = packersdk.MultiErrorAppend(
+			errs, errors.New("one of keep_latest, older_than or honor_expires_at is required"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packersdk.Ui, artifact packersdk.Artifact) (packersdk.Artifact, bool, bool, error) {
+	client := p.config.NewClient()
+
+	// Never delete the snapshots we just created
+	var excluded []int64
+	if artifact.BuilderId() == hcloudbuilder.BuilderId {
+		snapshotIDs, err := hcloudbuilder.SnapshotIDsFromArtifact(artifact)
+		if err != nil {
+			return artifact, true, false, err
+		}
+		excluded = snapshotIDs
+	}
+
+	selector := strings.Join(p.config.WithSelector, ",")
+	ui.Say(fmt.Sprintf("Listing snapshots with selector %q...", selector))
+	snapshots, err := client.Image.AllWithOpts(ctx, hcloud.ImageListOpts{
+		ListOpts: hcloud.ListOpts{LabelSelector: selector},
+		Type:     []hcloud.ImageType{hcloud.ImageTypeSnapshot},
+	})
+	if err != nil {
+		return artifact, true, false, fmt.Errorf("Could not fetch snapshots: %w", err)
+	}
+
+	var errs []error
+	for _, snapshot := range p.selectSnapshots(ui, snapshots, time.Now()) {
+		if slices.Contains(excluded, snapshot.ID) {
+			continue
+		}
+		if snapshot.Protection.Delete {
+			ui.Say(fmt.Sprintf("Skipping protected snapshot: %d (%s)", snapshot.ID, snapshot.Description))
+			continue
+		}
+
+		if p.config.DryRun {
+			ui.Say(fmt.Sprintf("Would delete snapshot: %d (%s, created %s)",
+				snapshot.ID, snapshot.Description, snapshot.Created.Format(time.RFC3339)))
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Deleting snapshot: %d (%s, created %s)",
+			snapshot.ID, snapshot.Description, snapshot.Created.Format(time.RFC3339)))
+		if _, err := client.Image.Delete(ctx, snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("Could not delete snapshot id=%d: %w", snapshot.ID, err))
+		}
+	}
+
+	return artifact, true, false, errors.Join(errs...)
+}
+
+// selectSnapshots returns the snapshots that must be deleted according to the
+// retention policy.
+//
+// Snapshots with an expired `expires_at` label are always selected. Otherwise,
+// a snapshot is selected when it matches all the configured policies: it is not
+// one of the `keep_latest` newest snapshots of its architecture, and it is
+// older than `older_than`.
+func (p *PostProcessor) selectSnapshots(ui packersdk.Ui, snapshots []*hcloud.Image, now time.Time) []*hcloud.Image {
+	snapshots = slices.Clone(snapshots)
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+
+	var selected []*hcloud.Image
+	rank := make(map[hcloud.Architecture]int)
+	for _, snapshot := range snapshots {
+		if p.config.HonorExpiresAt {
+			if value, ok := snapshot.Labels[ExpiresAtLabel]; ok {
+				expiresAt, err := parseExpiresAt(value)
+				if err != nil {
+					ui.Error(fmt.Sprintf("Ignoring invalid %s label on snapshot %d: %s", ExpiresAtLabel, snapshot.ID, err))
+				} else if expiresAt.Before(now) {
+					selected = append(selected, snapshot)
+					continue
+				}
+			}
+		}
+
+		// Expired snapshots do not count towards the newest snapshots to keep
+		rank[snapshot.Architecture]++
+
+		if p.config.KeepLatest == 0 && p.config.OlderThan == 0 {
+			continue
+		}
+		if p.config.KeepLatest > 0 && rank[snapshot.Architecture] <= p.config.KeepLatest {
+			continue
+		}
+		if p.config.OlderThan > 0 && snapshot.Created.After(now.Add(-p.config.OlderThan)) {
+			continue
+		}
+		selected = append(selected, snapshot)
+	}
+	return selected
+}
+
+// parseExpiresAt parses the value of the `expires_at` label, either a unix
+// timestamp or a date. Label values cannot contain colons, which rules out
+// RFC 3339 timestamps.
+func parseExpiresAt(value string) (time.Time, error) {
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(timestamp, 0), nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
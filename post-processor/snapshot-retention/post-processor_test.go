@@ -0,0 +1,184 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package snapshotretention
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packersdk.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "keep_latest",
+			raw:  map[string]interface{}{"with_selector": []string{"app=web"}, "keep_latest": 3},
+		},
+		{
+			name: "older_than",
+			raw:  map[string]interface{}{"with_selector": []string{"app=web"}, "older_than": "720h"},
+		},
+		{
+			name:    "missing selector",
+			raw:     map[string]interface{}{"keep_latest": 3},
+			wantErr: "with_selector is required",
+		},
+		{
+			name:    "missing policy",
+			raw:     map[string]interface{}{"with_selector": []string{"app=web"}},
+			wantErr: "one of keep_latest, older_than or honor_expires_at is required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			p := &PostProcessor{}
+			err := p.Configure(tc.raw)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSelectSnapshots(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	snapshots := []*hcloud.Image{
+		{ID: 1, Architecture: "x86", Created: now.Add(-1 * day)},
+		{ID: 2, Architecture: "x86", Created: now.Add(-10 * day)},
+		{ID: 3, Architecture: "x86", Created: now.Add(-20 * day)},
+		{ID: 4, Architecture: "arm", Created: now.Add(-30 * day)},
+		{ID: 5, Architecture: "x86", Created: now.Add(-2 * day), Labels: map[string]string{"expires_at": "2024-05-31"}},
+		{ID: 6, Architecture: "x86", Created: now.Add(-3 * day), Labels: map[string]string{"expires_at": "1717286400"}},
+	}
+
+	testCases := []struct {
+		name   string
+		config Config
+		want   []int64
+	}{
+		{
+			name:   "keep_latest",
+			config: Config{KeepLatest: 2},
+			want:   []int64{6, 2, 3},
+		},
+		{
+			name:   "older_than",
+			config: Config{OlderThan: 15 * day},
+			want:   []int64{3, 4},
+		},
+		{
+			name:   "keep_latest and older_than",
+			config: Config{KeepLatest: 4, OlderThan: 5 * day},
+			want:   []int64{3},
+		},
+		{
+			name:   "honor_expires_at",
+			config: Config{HonorExpiresAt: true},
+			want:   []int64{5},
+		},
+		{
+			name:   "honor_expires_at and keep_latest",
+			config: Config{HonorExpiresAt: true, KeepLatest: 3},
+			want:   []int64{5, 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &PostProcessor{config: tc.config}
+
+			var result []int64
+			for _, snapshot := range p.selectSnapshots(&packersdk.MockUi{}, snapshots, now) {
+				result = append(result, snapshot.ID)
+			}
+			assert.Equal(t, tc.want, result)
+		})
+	}
+}
+
+func TestPostProcess(t *testing.T) {
+	listRequest := mockutil.Request{
+		Method: "GET", Path: "/images?label_selector=app%3Dweb&page=1&per_page=50&type=snapshot",
+		Status: 200,
+		JSONRaw: `{
+			"images": [
+				{ "id": 1, "description": "new", "architecture": "x86", "created": "2024-03-01T00:00:00+00:00" },
+				{ "id": 2, "description": "old", "architecture": "x86", "created": "2024-02-01T00:00:00+00:00" },
+				{ "id": 3, "description": "protected", "architecture": "x86", "created": "2024-01-01T00:00:00+00:00", "protection": { "delete": true }},
+				{ "id": 4, "description": "older", "architecture": "x86", "created": "2023-12-01T00:00:00+00:00" }
+			],
+			"meta": { "pagination": { "page": 1 }}
+		}`,
+	}
+
+	testCases := []struct {
+		name         string
+		raw          map[string]interface{}
+		wantRequests []mockutil.Request
+	}{
+		{
+			name: "delete",
+			raw:  map[string]interface{}{"keep_latest": 1},
+			wantRequests: []mockutil.Request{
+				listRequest,
+				{Method: "DELETE", Path: "/images/2", Status: 204},
+				{Method: "DELETE", Path: "/images/4", Status: 204},
+			},
+		},
+		{
+			name: "dry run",
+			raw:  map[string]interface{}{"keep_latest": 1, "dry_run": true},
+			wantRequests: []mockutil.Request{
+				listRequest,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, tc.wantRequests))
+			defer server.Close()
+
+			tc.raw["token"] = "dummy"
+			tc.raw["endpoint"] = server.URL
+			tc.raw["with_selector"] = []string{"app=web"}
+
+			p := &PostProcessor{}
+			require.NoError(t, p.Configure(tc.raw))
+
+			// The artifact just created must never be deleted
+			artifact := &packersdk.MockArtifact{BuilderIdValue: hcloudbuilder.BuilderId, IdValue: "1"}
+
+			result, keep, forceOverride, err := p.PostProcess(context.Background(), &packersdk.MockUi{}, artifact)
+			require.NoError(t, err)
+			assert.Equal(t, artifact, result)
+			assert.True(t, keep)
+			assert.False(t, forceOverride)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package hcloudpower
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+const (
+	ActionReboot        = "reboot"
+	ActionReset         = "reset"
+	ActionPowerCycle    = "power_cycle"
+	ActionEnableRescue  = "enable_rescue"
+	ActionDisableRescue = "disable_rescue"
+)
+
+var actions = []string{ActionReboot, ActionReset, ActionPowerCycle, ActionEnableRescue, ActionDisableRescue}
+
+// bootIDCommand prints an ID that changes on every boot of a Linux system.
+const bootIDCommand = "cat /proc/sys/kernel/random/boot_id"
+
+// rebootRetryInterval is the interval between the checks whether the server
+// was rebooted, while it is still reachable.
+const rebootRetryInterval = 5 * time.Second
+
+type Config struct {
+	common.PackerConfig        `mapstructure:",squash"`
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	Action string `mapstructure:"action"`
+	Rescue string `mapstructure:"rescue"`
+
+	ReconnectTimeout time.Duration `mapstructure:"reconnect_timeout"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+}
+
+func (p *Provisioner) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hcloud-power",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	// Defaults
+	if p.config.Action == ActionEnableRescue && p.config.Rescue == "" {
+		p.config.Rescue = "linux64"
+	}
+	if p.config.ReconnectTimeout == 0 {
+		p.config.ReconnectTimeout = 5 * time.Minute
+	}
+
+	var errs *packersdk.MultiError
+	if es := p.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if !slices.Contains(actions, p.config.Action) {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("action must be one of %s", strings.Join(actions, ", ")))
+	}
+	if p.config.Rescue != "" && p.config.Action != ActionEnableRescue {
+		errs = packersdk.MultiErrorAppend(
+			errs, fmt.Errorf("rescue can only be used with the %s action", ActionEnableRescue))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, generatedData map[string]interface{}) error {
+	serverID, ok := hcloudbuilder.GeneratedDataID(generatedData, "ServerID")
+	if !ok {
+		return errors.New("the server ID is missing, the hcloud-power provisioner can only be used with the hcloud builder")
+	}
+	if comm == nil {
+		return errors.New("a communicator is required to reconnect to the server")
+	}
+
+	if p.config.Action == ActionEnableRescue {
+		// The communicator reconnects with the settings of the build, the
+		// rescue system only accepts root and has its own host key
+		if user, _ := generatedData["User"].(string); user != "" && user != "root" {
+			return fmt.Errorf("the %s action requires the communicator to connect as root, not %q", ActionEnableRescue, user)
+		}
+		if pinned, _ := generatedData["SSHHostKeyPinned"].(bool); pinned {
+			return fmt.Errorf("the %s action cannot be used with pin_ssh_host_key", ActionEnableRescue)
+		}
+	}
+
+	client := p.config.NewClient()
+	server := &hcloud.Server{ID: serverID}
+
+	// The boot ID tells whether the server was rebooted, a reboot is only
+	// requested to the guest and might not have happened yet once the API
+	// action completed
+	bootID := readBootID(comm)
+
+	switch p.config.Action {
+	case ActionReboot:
+		ui.Say("Rebooting server...")
+		if err := runAction(ctx, client, server, client.Server.Reboot); err != nil {
+			return fmt.Errorf("Could not reboot server: %w", err)
+		}
+
+	case ActionReset:
+		ui.Say("Resetting server...")
+		if err := runAction(ctx, client, server, client.Server.Reset); err != nil {
+			return fmt.Errorf("Could not reset server: %w", err)
+		}
+
+	case ActionPowerCycle:
+		ui.Say("Powering server off...")
+		if err := runAction(ctx, client, server, client.Server.Poweroff); err != nil {
+			return fmt.Errorf("Could not power off server: %w", err)
+		}
+		ui.Say("Powering server on...")
+		if err := runAction(ctx, client, server, client.Server.Poweron); err != nil {
+			return fmt.Errorf("Could not power on server: %w", err)
+		}
+
+	case ActionEnableRescue:
+		sshKeyID, ok := hcloudbuilder.GeneratedDataID(generatedData, "SSHKeyID")
+		if !ok {
+			return errors.New("the SSH key ID is missing, the hcloud-power provisioner can only be used with the hcloud builder")
+		}
+
+		ui.Say("Enabling rescue mode...")
+		result, _, err := client.Server.EnableRescue(ctx, server, hcloud.ServerEnableRescueOpts{
+			Type:    hcloud.ServerRescueType(p.config.Rescue),
+			SSHKeys: []*hcloud.SSHKey{{ID: sshKeyID}},
+		})
+		if err != nil {
+			return fmt.Errorf("Could not enable rescue mode: %w", err)
+		}
+		if err := client.Action.WaitFor(ctx, result.Action); err != nil {
+			return fmt.Errorf("Could not enable rescue mode: %w", err)
+		}
+		ui.Say("Rebooting server into rescue mode...")
+		if err := runAction(ctx, client, server, client.Server.Reset); err != nil {
+			return fmt.Errorf("Could not reset server: %w", err)
+		}
+
+	case ActionDisableRescue:
+		ui.Say("Disabling rescue mode...")
+		if err := runAction(ctx, client, server, client.Server.DisableRescue); err != nil {
+			return fmt.Errorf("Could not disable rescue mode: %w", err)
+		}
+		ui.Say("Rebooting server out of rescue mode...")
+		if err := runAction(ctx, client, server, client.Server.Reset); err != nil {
+			return fmt.Errorf("Could not reset server: %w", err)
+		}
+	}
+
+	ui.Say("Waiting for the communicator to reconnect...")
+	if err := waitForReboot(ctx, ui, comm, p.config.ReconnectTimeout, bootID); err != nil {
+		return fmt.Errorf("Could not reconnect to server: %w", err)
+	}
+
+	return nil
+}
+
+// runAction runs a server action and waits for it to complete.
+func runAction(
+	ctx context.Context,
+	client *hcloud.Client,
+	server *hcloud.Server,
+	fn func(context.Context, *hcloud.Server) (*hcloud.Action, *hcloud.Response, error),
+) error {
+	action, _, err := fn(ctx, server)
+	if err != nil {
+		return err
+	}
+	return client.Action.WaitFor(ctx, action)
+}
+
+// readBootID returns the boot ID of the server, or an empty string if it could
+// not be read, for example on systems other than Linux.
+func readBootID(comm packersdk.Communicator) string {
+	stdout := new(bytes.Buffer)
+	cmd := &packersdk.RemoteCmd{Command: bootIDCommand, Stdout: stdout}
+	if err := comm.Start(context.Background(), cmd); err != nil {
+		return ""
+	}
+	if cmd.Wait() != 0 {
+		return ""
+	}
+	return strings.TrimSpace(stdout.String())
+}
+
+// waitForReboot waits for the communicator to reconnect to the server, and
+// for the boot ID to change when it was known before the reboot.
+func waitForReboot(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, timeout time.Duration, previousBootID string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		if err := hcloudbuilder.WaitForCommunicator(ctx, ui, comm, timeout); err != nil {
+			return err
+		}
+		if previousBootID == "" {
+			return nil
+		}
+		if bootID := readBootID(comm); bootID != "" && bootID != previousBootID {
+			return nil
+		}
+		log.Printf("[DEBUG] Server was not rebooted yet")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rebootRetryInterval):
+		}
+	}
+}
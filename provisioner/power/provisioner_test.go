@@ -0,0 +1,229 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudpower
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+// bootIDCommunicator returns the next boot ID each time it is read.
+type bootIDCommunicator struct {
+	packersdk.MockCommunicator
+
+	bootIDs []string
+}
+
+func (c *bootIDCommunicator) Start(ctx context.Context, cmd *packersdk.RemoteCmd) error {
+	if cmd.Command == bootIDCommand && len(c.bootIDs) > 0 {
+		io.Copy(cmd.Stdout, strings.NewReader(c.bootIDs[0]+"\n")) //nolint:errcheck
+		c.bootIDs = c.bootIDs[1:]
+	}
+	cmd.SetExited(0)
+	return nil
+}
+
+func TestProvisioner_ImplementsProvisioner(t *testing.T) {
+	var _ packersdk.Provisioner = new(Provisioner)
+}
+
+func TestProvisionerPrepare(t *testing.T) {
+	testCases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "reboot",
+			raw:  map[string]interface{}{"action": "reboot"},
+		},
+		{
+			name: "enable_rescue",
+			raw:  map[string]interface{}{"action": "enable_rescue", "rescue": "linux64"},
+		},
+		{
+			name:    "missing action",
+			raw:     map[string]interface{}{},
+			wantErr: "action must be one of reboot, reset, power_cycle, enable_rescue, disable_rescue",
+		},
+		{
+			name:    "rescue without enable_rescue",
+			raw:     map[string]interface{}{"action": "reset", "rescue": "linux64"},
+			wantErr: "rescue can only be used with the enable_rescue action",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["token"] = "dummy"
+
+			p := &Provisioner{}
+			err := p.Prepare(tc.raw)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvision(t *testing.T) {
+	testCases := []struct {
+		name         string
+		raw          map[string]interface{}
+		wantRequests []mockutil.Request
+	}{
+		{
+			name: "reset",
+			raw:  map[string]interface{}{"action": "reset"},
+			wantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/42/actions/reset",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 1, "status": "success" }
+					}`,
+				},
+			},
+		},
+		{
+			name: "power_cycle",
+			raw:  map[string]interface{}{"action": "power_cycle"},
+			wantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/42/actions/poweroff",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 1, "status": "success" }
+					}`,
+				},
+				{Method: "POST", Path: "/servers/42/actions/poweron",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 2, "status": "success" }
+					}`,
+				},
+			},
+		},
+		{
+			name: "enable_rescue",
+			raw:  map[string]interface{}{"action": "enable_rescue"},
+			wantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/42/actions/enable_rescue",
+					Want: func(t *testing.T, req *http.Request) {
+						payload := &schema.ServerActionEnableRescueRequest{}
+						require.NoError(t, json.NewDecoder(req.Body).Decode(payload))
+						assert.Equal(t, "linux64", *payload.Type)
+						assert.Equal(t, []int64{7}, payload.SSHKeys)
+					},
+					Status: 201,
+					JSONRaw: `{
+						"root_password": "dummy",
+						"action": { "id": 1, "status": "success" }
+					}`,
+				},
+				{Method: "POST", Path: "/servers/42/actions/reset",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 2, "status": "success" }
+					}`,
+				},
+			},
+		},
+		{
+			name: "disable_rescue",
+			raw:  map[string]interface{}{"action": "disable_rescue"},
+			wantRequests: []mockutil.Request{
+				{Method: "POST", Path: "/servers/42/actions/disable_rescue",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 1, "status": "success" }
+					}`,
+				},
+				{Method: "POST", Path: "/servers/42/actions/reset",
+					Status: 201,
+					JSONRaw: `{
+						"action": { "id": 2, "status": "success" }
+					}`,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(mockutil.Handler(t, tc.wantRequests))
+			defer server.Close()
+
+			tc.raw["token"] = "dummy"
+			tc.raw["endpoint"] = server.URL
+
+			p := &Provisioner{}
+			require.NoError(t, p.Prepare(tc.raw))
+
+			comm := &bootIDCommunicator{bootIDs: []string{"before", "after"}}
+			err := p.Provision(context.Background(), &packersdk.MockUi{}, comm, map[string]interface{}{
+				"ServerID":         int64(42),
+				"SSHKeyID":         int64(7),
+				"SSHHostKeyPinned": false,
+				"User":             "root",
+			})
+			require.NoError(t, err)
+			assert.Empty(t, comm.bootIDs)
+		})
+	}
+}
+
+func TestProvisionWithoutServerID(t *testing.T) {
+	p := &Provisioner{}
+	require.NoError(t, p.Prepare(map[string]interface{}{"token": "dummy", "action": "reboot"}))
+
+	err := p.Provision(context.Background(), &packersdk.MockUi{}, &packersdk.MockCommunicator{}, map[string]interface{}{})
+	assert.ErrorContains(t, err, "can only be used with the hcloud builder")
+}
+
+func TestProvisionEnableRescueUnsupportedCommunicator(t *testing.T) {
+	testCases := []struct {
+		name          string
+		generatedData map[string]interface{}
+		wantErr       string
+	}{
+		{
+			name:          "not root",
+			generatedData: map[string]interface{}{"User": "debian"},
+			wantErr:       `the enable_rescue action requires the communicator to connect as root, not "debian"`,
+		},
+		{
+			name:          "pinned host key",
+			generatedData: map[string]interface{}{"User": "root", "SSHHostKeyPinned": true},
+			wantErr:       "the enable_rescue action cannot be used with pin_ssh_host_key",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &Provisioner{}
+			require.NoError(t, p.Prepare(map[string]interface{}{"token": "dummy", "action": "enable_rescue"}))
+
+			tc.generatedData["ServerID"] = int64(42)
+			tc.generatedData["SSHKeyID"] = int64(7)
+
+			comm := &packersdk.MockCommunicator{}
+			err := p.Provision(context.Background(), &packersdk.MockUi{}, comm, tc.generatedData)
+			assert.EqualError(t, err, tc.wantErr)
+			assert.False(t, comm.StartCalled)
+		})
+	}
+}
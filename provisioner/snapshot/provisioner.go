@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package hcloudsnapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud"
+	hcloudbuilder "github.com/hetznercloud/packer-plugin-hcloud/builder/hcloud"
+)
+
+type Config struct {
+	common.PackerConfig        `mapstructure:",squash"`
+	hcloudbuilder.ClientConfig `mapstructure:",squash"`
+
+	SnapshotName   string            `mapstructure:"snapshot_name"`
+	SnapshotLabels map[string]string `mapstructure:"snapshot_labels"`
+
+	ShutdownTimeout  time.Duration `mapstructure:"shutdown_timeout"`
+	ReconnectTimeout time.Duration `mapstructure:"reconnect_timeout"`
+
+	ctx interpolate.Context
+}
+
+type Provisioner struct {
+	config Config
+}
+
+func (p *Provisioner) ConfigSpec() hcldec.ObjectSpec { return p.config.FlatMapstructure().HCL2Spec() }
+
+func (p *Provisioner) Prepare(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "hcloud-snapshot",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	// Defaults
+	if p.config.SnapshotName == "" {
+		def, err := interpolate.Render("packer-{{timestamp}}", nil)
+		if err != nil {
+			panic(err)
+		}
+		// Default to packer-{{ unix timestamp (utc) }}
+		p.config.SnapshotName = def
+	}
+	if p.config.ShutdownTimeout == 0 {
+		p.config.ShutdownTimeout = 5 * time.Minute
+	}
+	if p.config.ReconnectTimeout == 0 {
+		p.config.ReconnectTimeout = 5 * time.Minute
+	}
+
+	var errs *packersdk.MultiError
+	if es := p.config.ClientConfig.Prepare(); len(es) > 0 {
+		errs = packersdk.MultiErrorAppend(errs, es...)
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *Provisioner) Provision(ctx context.Context, ui packersdk.Ui, comm packersdk.Communicator, generatedData map[string]interface{}) error {
+	serverID, ok := hcloudbuilder.GeneratedDataID(generatedData, "ServerID")
+	if !ok {
+		return errors.New("the server ID is missing, the hcloud-snapshot provisioner can only be used with the hcloud builder")
+	}
+	if comm == nil {
+		return errors.New("a communicator is required to reconnect to the server")
+	}
+
+	client := p.config.NewClient()
+	server := &hcloud.Server{ID: serverID}
+
+	ui.Say("Shutting down server...")
+	action, _, err := client.Server.Shutdown(ctx, server)
+	if err != nil {
+		return fmt.Errorf("Error stopping server: %w", err)
+	}
+	if err := client.Action.WaitFor(ctx, action); err != nil {
+		return fmt.Errorf("Error stopping server: %w", err)
+	}
+	if err := hcloudbuilder.WaitForPowerOff(ctx, ui, client, serverID, p.config.ShutdownTimeout, p.config.PollInterval); err != nil {
+		return fmt.Errorf("Error stopping server: %w", err)
+	}
+
+	// The label allows the builder to find the snapshot, to reference it in
+	// the artifact or to delete it when the build fails
+	labels := maps.Clone(p.config.SnapshotLabels)
+	if labels == nil {
+		labels = make(map[string]string, 1)
+	}
+	labels[hcloudbuilder.IntermediateSnapshotLabel] = strconv.FormatInt(serverID, 10)
+
+	ui.Say(fmt.Sprintf("Creating snapshot '%s'...", p.config.SnapshotName))
+	result, _, err := client.Server.CreateImage(ctx, server, &hcloud.ServerCreateImageOpts{
+		Type:        hcloud.ImageTypeSnapshot,
+		Labels:      labels,
+		Description: hcloud.Ptr(p.config.SnapshotName),
+	})
+	if err != nil {
+		return fmt.Errorf("Could not create snapshot: %w", err)
+	}
+	if err := client.Action.WaitFor(ctx, result.Action); err != nil {
+		return fmt.Errorf("Could not create snapshot: %w", err)
+	}
+	ui.Say(fmt.Sprintf("Created snapshot with ID: %d", result.Image.ID))
+
+	ui.Say("Starting server...")
+	action, _, err = client.Server.Poweron(ctx, server)
+	if err != nil {
+		return fmt.Errorf("Could not start server: %w", err)
+	}
+	if err := client.Action.WaitFor(ctx, action); err != nil {
+		return fmt.Errorf("Could not start server: %w", err)
+	}
+
+	ui.Say("Waiting for the communicator to reconnect...")
+	if err := hcloudbuilder.WaitForCommunicator(ctx, ui, comm, p.config.ReconnectTimeout); err != nil {
+		return fmt.Errorf("Could not reconnect to server: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package hcloudsnapshot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/exp/mockutil"
+	"github.com/hetznercloud/hcloud-go/v2/hcloud/schema"
+)
+
+func TestProvisioner_ImplementsProvisioner(t *testing.T) {
+	var _ packersdk.Provisioner = new(Provisioner)
+}
+
+func TestProvisionerPrepare(t *testing.T) {
+	p := &Provisioner{}
+	require.NoError(t, p.Prepare(map[string]interface{}{"token": "dummy"}))
+	assert.Regexp(t, "^packer-[0-9]+$", p.config.SnapshotName)
+	assert.Equal(t, 5*time.Minute, p.config.ShutdownTimeout)
+	assert.Equal(t, 5*time.Minute, p.config.ReconnectTimeout)
+
+	t.Setenv("HCLOUD_TOKEN", "")
+	p = &Provisioner{}
+	assert.ErrorContains(t, p.Prepare(map[string]interface{}{}), "token is missing")
+}
+
+func TestProvision(t *testing.T) {
+	server := httptest.NewServer(mockutil.Handler(t, []mockutil.Request{
+		{Method: "POST", Path: "/servers/42/actions/shutdown",
+			Status: 201,
+			JSONRaw: `{
+				"action": { "id": 1, "status": "success" }
+			}`,
+		},
+		{Method: "GET", Path: "/servers/42",
+			Status: 200,
+			JSONRaw: `{
+				"server": { "id": 42, "status": "off" }
+			}`,
+		},
+		{Method: "POST", Path: "/servers/42/actions/create_image",
+			Want: func(t *testing.T, req *http.Request) {
+				payload := &schema.ServerActionCreateImageRequest{}
+				require.NoError(t, json.NewDecoder(req.Body).Decode(payload))
+				assert.Equal(t, "base", *payload.Description)
+				assert.Equal(t, "snapshot", *payload.Type)
+				assert.Equal(t, map[string]string{
+					"layer":                              "base",
+					"packer.io/intermediate-snapshot-of": "42",
+				}, *payload.Labels)
+			},
+			Status: 201,
+			JSONRaw: `{
+				"image": { "id": 16, "description": "base", "type": "snapshot" },
+				"action": { "id": 2, "status": "success" }
+			}`,
+		},
+		{Method: "POST", Path: "/servers/42/actions/poweron",
+			Status: 201,
+			JSONRaw: `{
+				"action": { "id": 3, "status": "success" }
+			}`,
+		},
+	}))
+	defer server.Close()
+
+	p := &Provisioner{}
+	require.NoError(t, p.Prepare(map[string]interface{}{
+		"token":           "dummy",
+		"endpoint":        server.URL,
+		"snapshot_name":   "base",
+		"snapshot_labels": map[string]string{"layer": "base"},
+	}))
+
+	comm := &packersdk.MockCommunicator{}
+	err := p.Provision(context.Background(), &packersdk.MockUi{}, comm, map[string]interface{}{"ServerID": int64(42)})
+	require.NoError(t, err)
+	assert.Equal(t, "true", comm.StartCmd.Command)
+}
+
+func TestProvisionWithoutServerID(t *testing.T) {
+	p := &Provisioner{}
+	require.NoError(t, p.Prepare(map[string]interface{}{"token": "dummy"}))
+
+	err := p.Provision(context.Background(), &packersdk.MockUi{}, &packersdk.MockCommunicator{}, map[string]interface{}{})
+	assert.ErrorContains(t, err, "can only be used with the hcloud builder")
+}